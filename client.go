@@ -1,22 +1,102 @@
 package deimosclient
 
 import (
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
 )
 
 type Client struct {
-	cluster    *Cluster
-	httpClient *http.Client
+	cluster        *Cluster
+	httpClient     *http.Client
+	logger         *slog.Logger
+	userAgent      string
+	requestTimeout time.Duration
+	watchTimeout   time.Duration
+}
+
+// ClientOptions contains options for creating a client
+type ClientOptions struct {
+	httpClient     *http.Client
+	transport      http.RoundTripper
+	requestTimeout time.Duration
+	watchTimeout   time.Duration
+	dialTimeout    time.Duration
+	logger         *slog.Logger
+	userAgent      string
+	tls            tlsOptions
+}
+
+// DefaultClientOptions returns default client options
+func DefaultClientOptions() *ClientOptions {
+	return &ClientOptions{
+		requestTimeout: 3 * time.Second,
+		dialTimeout:    2 * time.Second,
+		userAgent:      "deimos-client/" + Version,
+	}
+}
+
+func newClientOptions(options []ClientOption) *ClientOptions {
+	opts := DefaultClientOptions()
+	for _, opt := range options {
+		opt.applyToClient(opts)
+	}
+	return opts
 }
 
 // NewClient create a basic client that is configured to be used
 // with the given machine list.
-func NewClient(endpoints []string) *Client {
+func NewClient(endpoints []string, opts ...ClientOption) *Client {
+	clientOpts := newClientOptions(opts)
+
+	logger := clientOpts.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &Client{
-		cluster: NewCluster(endpoints),
-		httpClient: &http.Client{
-			Timeout: 3 * time.Second,
-		},
+		cluster:        NewCluster(endpoints),
+		httpClient:     clientOpts.buildHTTPClient(),
+		logger:         logger,
+		userAgent:      clientOpts.userAgent,
+		requestTimeout: clientOpts.requestTimeout,
+		watchTimeout:   clientOpts.watchTimeout,
+	}
+}
+
+// buildHTTPClient returns the http.Client used by the client. A client
+// given through WithHTTPClient is copied so that setting the transport
+// does not affect the caller's instance.
+func (o *ClientOptions) buildHTTPClient() *http.Client {
+	httpClient := &http.Client{}
+	if o.httpClient != nil {
+		copied := *o.httpClient
+		httpClient = &copied
+	}
+
+	switch {
+	case o.transport != nil:
+		httpClient.Transport = o.transport
+	case httpClient.Transport == nil:
+		httpClient.Transport = o.buildTransport()
+	}
+
+	return httpClient
+}
+
+// buildTransport returns the default transport with the dial timeout and
+// TLS settings applied.
+func (o *ClientOptions) buildTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   o.dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	if o.tls.enabled() {
+		transport.DialTLSContext = newTLSReloader(o.tls).dialContext(dialer)
 	}
+	return transport
 }
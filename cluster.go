@@ -3,13 +3,20 @@ package deimosclient
 import (
 	"log/slog"
 	"math/rand"
+	"strings"
 	"sync"
+	"time"
 )
 
+// unhealthyCooldown is how long an endpoint that failed at the connection
+// level is passed over when failure rotates to the next endpoint.
+const unhealthyCooldown = 10 * time.Second
+
 type Cluster struct {
 	Leader    string   `json:"leader"`
 	Endpoints []string `json:"endpoints"`
 	picked    int
+	unhealthy map[string]time.Time
 	mu        sync.RWMutex
 }
 
@@ -26,6 +33,7 @@ func NewCluster(endpoints []string) *Cluster {
 		Leader:    "",
 		Endpoints: endpoints,
 		picked:    rand.Intn(len(endpoints)),
+		unhealthy: make(map[string]time.Time),
 	}
 }
 
@@ -34,3 +42,119 @@ func (cl *Cluster) pick() string {
 	defer cl.mu.Unlock()
 	return cl.Endpoints[cl.picked]
 }
+
+// pickLeader returns the known leader, or falls back to pick when the
+// leader has not been discovered yet.
+func (cl *Cluster) pickLeader() string {
+	cl.mu.RLock()
+	leader := cl.Leader
+	cl.mu.RUnlock()
+
+	if leader != "" {
+		return leader
+	}
+	return cl.pick()
+}
+
+// updateLeader records the endpoint of the current leader.
+func (cl *Cluster) updateLeader(leader string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if sameEndpoint(cl.Leader, leader) {
+		return
+	}
+	slog.Debug("Update cluster leader", "from", cl.Leader, "to", leader)
+	cl.Leader = leader
+}
+
+// update replaces the endpoint list with the given membership. The picked
+// endpoint and the leader are kept if they are still members.
+func (cl *Cluster) update(endpoints []string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	current := cl.Endpoints[cl.picked]
+	endpoints = shuffleStringSlice(endpoints)
+
+	cl.picked = rand.Intn(len(endpoints))
+	leaderFound := false
+	for i, endpoint := range endpoints {
+		if sameEndpoint(endpoint, current) {
+			cl.picked = i
+		}
+		if sameEndpoint(endpoint, cl.Leader) {
+			leaderFound = true
+		}
+	}
+	if !leaderFound {
+		cl.Leader = ""
+	}
+
+	unhealthy := make(map[string]time.Time)
+	for _, endpoint := range endpoints {
+		if failedAt, ok := cl.unhealthy[normalizeEndpoint(endpoint)]; ok {
+			unhealthy[normalizeEndpoint(endpoint)] = failedAt
+		}
+	}
+
+	slog.Debug("Sync cluster", "machines", endpoints)
+	cl.Endpoints = endpoints
+	cl.unhealthy = unhealthy
+}
+
+// size returns the number of known endpoints.
+func (cl *Cluster) size() int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+	return len(cl.Endpoints)
+}
+
+// failure marks the given endpoint as unhealthy and, if it is the one
+// currently picked, rotates to the next healthy endpoint in the shuffled
+// list. When every endpoint is unhealthy it simply moves to the next one.
+func (cl *Cluster) failure(endpoint string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	now := time.Now()
+	cl.unhealthy[normalizeEndpoint(endpoint)] = now
+
+	// An unreachable leader has to be rediscovered.
+	if sameEndpoint(cl.Leader, endpoint) {
+		cl.Leader = ""
+	}
+
+	if !sameEndpoint(cl.Endpoints[cl.picked], endpoint) {
+		// Someone else already rotated away from the failed endpoint.
+		return
+	}
+
+	for i := 1; i <= len(cl.Endpoints); i++ {
+		next := (cl.picked + i) % len(cl.Endpoints)
+		failedAt, ok := cl.unhealthy[normalizeEndpoint(cl.Endpoints[next])]
+		if !ok || now.Sub(failedAt) > unhealthyCooldown {
+			cl.picked = next
+			slog.Debug("Rotate cluster endpoint", "failed", endpoint, "picked", cl.Endpoints[next])
+			return
+		}
+	}
+
+	// All endpoints are unhealthy, try the next one anyway.
+	cl.picked = (cl.picked + 1) % len(cl.Endpoints)
+}
+
+// success clears the unhealthy mark of the given endpoint.
+func (cl *Cluster) success(endpoint string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	delete(cl.unhealthy, normalizeEndpoint(endpoint))
+}
+
+func normalizeEndpoint(endpoint string) string {
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+func sameEndpoint(a, b string) bool {
+	return normalizeEndpoint(a) == normalizeEndpoint(b)
+}
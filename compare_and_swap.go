@@ -14,6 +14,7 @@ type CompareAndSwapOptions struct {
 	ttl       time.Duration
 	prevValue string
 	prevIndex uint64
+	refresh   bool
 }
 
 type CompareAndSwapOption interface {
@@ -32,9 +33,14 @@ func newCompareAndSwapOptions(options []CompareAndSwapOption) *CompareAndSwapOpt
 func (c *Client) CompareAndSwap(ctx context.Context, key, value string, opts ...CompareAndSwapOption) (*Response, error) {
 	casOpts := newCompareAndSwapOptions(opts)
 
-	URL := c.buildURL(key)
+	URL := c.buildLeaderURL(key)
 	query := url.Values{}
-	query.Set("value", value)
+	if casOpts.refresh {
+		// A refresh keeps the current value.
+		query.Set("refresh", "true")
+	} else {
+		query.Set("value", value)
+	}
 
 	if casOpts.ttl > 0 {
 		query.Set("ttl", fmt.Sprintf("%d", int64(casOpts.ttl.Seconds())))
@@ -62,7 +68,7 @@ func (c *Client) CompareAndSwap(ctx context.Context, key, value string, opts ...
 func (c *Client) CompareAndDelete(ctx context.Context, key string, opts ...CompareAndDeleteOption) (*Response, error) {
 	cadOpts := newCompareAndDeleteOptions(opts)
 
-	URL := c.buildURL(key)
+	URL := c.buildLeaderURL(key)
 	query := url.Values{}
 
 	if cadOpts.prevValue != "" {
@@ -119,6 +125,13 @@ func WithCasTTL(ttl time.Duration) CompareAndSwapOption {
 	return &casTTLOption{ttl: ttl}
 }
 
+// WithCasRefresh makes CompareAndSwap only extend the TTL of the key, given
+// with WithCasTTL, without changing its value or notifying its watchers.
+// The value passed to CompareAndSwap is ignored.
+func WithCasRefresh() CompareAndSwapOption {
+	return &casRefreshOption{refresh: true}
+}
+
 type prevValueOption struct {
 	prevValue string
 }
@@ -150,3 +163,11 @@ type casTTLOption struct {
 func (o *casTTLOption) applyToCompareAndSwap(opts *CompareAndSwapOptions) {
 	opts.ttl = o.ttl
 }
+
+type casRefreshOption struct {
+	refresh bool
+}
+
+func (o *casRefreshOption) applyToCompareAndSwap(opts *CompareAndSwapOptions) {
+	opts.refresh = o.refresh
+}
@@ -0,0 +1,65 @@
+package deimosclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type CreateInOrderOptions struct {
+	ttl time.Duration
+}
+
+type CreateInOrderOption interface {
+	applyToCreateInOrder(*CreateInOrderOptions)
+}
+
+func newCreateInOrderOptions(options []CreateInOrderOption) *CreateInOrderOptions {
+	opts := CreateInOrderOptions{}
+	for _, opt := range options {
+		opt.applyToCreateInOrder(&opts)
+	}
+	return &opts
+}
+
+// CreateInOrder atomically creates a key under dir named after the index
+// of the creation, so that listing the directory WithSorted yields the
+// keys in creation order. The generated key and its index are in the
+// returned Node. The directory is created if it does not exist.
+func (c *Client) CreateInOrder(ctx context.Context, dir, value string, opts ...CreateInOrderOption) (*Response, error) {
+	createOpts := newCreateInOrderOptions(opts)
+
+	URL := c.buildLeaderURL(dir)
+	query := url.Values{}
+	query.Set("value", value)
+
+	if createOpts.ttl > 0 {
+		query.Set("ttl", fmt.Sprintf("%d", int64(createOpts.ttl.Seconds())))
+	}
+
+	body := strings.NewReader(query.Encode())
+	req, err := http.NewRequestWithContext(ctx, "POST", URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.doRequest(req)
+}
+
+// WithCreateInOrderTTL makes the key created by CreateInOrder expire after
+// the given TTL.
+func WithCreateInOrderTTL(ttl time.Duration) CreateInOrderOption {
+	return &createInOrderTTLOption{ttl: ttl}
+}
+
+type createInOrderTTLOption struct {
+	ttl time.Duration
+}
+
+func (o *createInOrderTTLOption) applyToCreateInOrder(opts *CreateInOrderOptions) {
+	opts.ttl = o.ttl
+}
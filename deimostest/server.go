@@ -0,0 +1,345 @@
+// Package deimostest provides an in-process deimos server for unit tests,
+// so that code depending on *deimosclient.Client can be tested without a
+// real cluster.
+//
+//	srv := deimostest.NewServer()
+//	defer srv.Close()
+//
+//	client := srv.Client()
+//	client.Set(ctx, "/foo", "bar")
+package deimostest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+const (
+	// defaultHistorySize is the number of events kept for watchers.
+	defaultHistorySize = 1000
+	// expireInterval is how often TTLs are checked in the background.
+	expireInterval = 50 * time.Millisecond
+)
+
+// Server is an in-memory implementation of the deimos /keys and /machines
+// endpoints served over HTTP.
+type Server struct {
+	// URL is the endpoint of the server, to be passed to NewClient.
+	URL string
+
+	httpServer *httptest.Server
+	store      *store
+	stopCh     chan struct{}
+	doneCh     chan struct{}
+}
+
+// Options contains options for creating a server
+type Options struct {
+	historySize int
+}
+
+// Option configures a Server
+type Option interface {
+	applyToServer(*Options)
+}
+
+// WithHistorySize sets how many events are kept for watchers. Watching
+// from an older index fails with an event index cleared error.
+func WithHistorySize(size int) Option {
+	return &historySizeOption{size: size}
+}
+
+type historySizeOption struct {
+	size int
+}
+
+func (o *historySizeOption) applyToServer(opts *Options) {
+	opts.historySize = o.size
+}
+
+// NewServer starts a server with an empty store. It must be closed with
+// Close.
+func NewServer(opts ...Option) *Server {
+	serverOpts := Options{historySize: defaultHistorySize}
+	for _, opt := range opts {
+		opt.applyToServer(&serverOpts)
+	}
+
+	s := &Server{
+		store:  newStore(serverOpts.historySize),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys", s.handleKeys)
+	mux.HandleFunc("/keys/", s.handleKeys)
+	mux.HandleFunc("/machines", s.handleMachines)
+
+	s.httpServer = httptest.NewServer(mux)
+	s.URL = s.httpServer.URL
+
+	go s.expireLoop()
+
+	return s
+}
+
+// Client returns a client connected to the server.
+func (s *Server) Client(opts ...deimosclient.ClientOption) *deimosclient.Client {
+	return deimosclient.NewClient([]string{s.URL}, opts...)
+}
+
+// Index returns the index of the last change in the store.
+func (s *Server) Index() uint64 {
+	return s.store.currentIndex()
+}
+
+// Close shuts the server down, ending any pending watch.
+func (s *Server) Close() {
+	close(s.stopCh)
+	<-s.doneCh
+	s.httpServer.CloseClientConnections()
+	s.httpServer.Close()
+}
+
+// expireLoop removes expired keys in the background so that watchers see
+// the expire events without any other request coming in.
+func (s *Server) expireLoop() {
+	defer close(s.doneCh)
+
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case now := <-ticker.C:
+			s.store.expire(now)
+		}
+	}
+}
+
+func (s *Server) handleMachines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	_, _ = io.WriteString(w, s.URL)
+}
+
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	key := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/keys"))
+
+	form, err := parseForm(r)
+	if err != nil {
+		s.writeError(w, s.store.newError(deimosclient.ErrCodeInvalidForm, err.Error()))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGet(w, r, key, form)
+	case http.MethodPut:
+		s.handlePut(w, key, form)
+	case http.MethodPost:
+		s.handlePost(w, key, form)
+	case http.MethodDelete:
+		s.handleDelete(w, key, form)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, key string, form url.Values) {
+	recursive := form.Get("recursive") == "true"
+
+	if form.Get("wait") != "true" {
+		e, err := s.store.get(key, recursive, form.Get("sorted") == "true")
+		s.write(w, e, http.StatusOK, err)
+		return
+	}
+
+	waitIndex, err := parseIndex(form.Get("waitIndex"))
+	if err != nil {
+		s.writeError(w, s.store.newError(deimosclient.ErrCodeIndexNaN, "waitIndex"))
+		return
+	}
+
+	e, err := s.store.watch(r.Context(), key, recursive, waitIndex)
+	if err != nil && r.Context().Err() != nil {
+		// The client went away.
+		return
+	}
+	s.write(w, e, http.StatusOK, err)
+}
+
+func (s *Server) handlePut(w http.ResponseWriter, key string, form url.Values) {
+	req, err := s.parseRequest(form)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	e, created, err := s.store.set(key, req)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	s.write(w, e, status, err)
+}
+
+func (s *Server) handlePost(w http.ResponseWriter, key string, form url.Values) {
+	req, err := s.parseRequest(form)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	e, err := s.store.createInOrder(key, req)
+	s.write(w, e, http.StatusCreated, err)
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, key string, form url.Values) {
+	req, err := s.parseRequest(form)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	e, err := s.store.delete(key, req)
+	s.write(w, e, http.StatusOK, err)
+}
+
+// parseRequest reads the write parameters from the form.
+func (s *Server) parseRequest(form url.Values) (*request, error) {
+	req := &request{
+		value:     form.Get("value"),
+		dir:       form.Get("dir") == "true",
+		prevExist: form.Get("prevExist"),
+		prevValue: form.Get("prevValue"),
+		recursive: form.Get("recursive") == "true",
+		refresh:   form.Get("refresh") == "true",
+	}
+
+	if ttl := form.Get("ttl"); ttl != "" {
+		seconds, err := strconv.ParseInt(ttl, 10, 64)
+		if err != nil {
+			return nil, s.store.newError(deimosclient.ErrCodeTTLNaN, "ttl")
+		}
+		d := time.Duration(seconds) * time.Second
+		req.ttl = &d
+	}
+
+	prevIndex, err := parseIndex(form.Get("prevIndex"))
+	if err != nil {
+		return nil, s.store.newError(deimosclient.ErrCodeIndexNaN, "prevIndex")
+	}
+	req.prevIndex = prevIndex
+
+	if req.prevExist != "" && req.prevExist != "true" && req.prevExist != "false" {
+		return nil, s.store.newError(deimosclient.ErrCodeInvalidField, "prevExist")
+	}
+
+	if req.refresh {
+		if req.value != "" {
+			return nil, s.store.newError(deimosclient.ErrCodeRefreshValue, "value")
+		}
+		if req.ttl == nil {
+			return nil, s.store.newError(deimosclient.ErrCodeRefreshTTLRequired, "ttl")
+		}
+	}
+
+	return req, nil
+}
+
+func (s *Server) write(w http.ResponseWriter, e *event, status int, err error) {
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	s.writeHeaders(w)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&deimosclient.Response{
+		Action:   e.action,
+		Node:     e.node,
+		PrevNode: e.prevNode,
+	})
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	var apiErr *deimosclient.APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = s.store.newError(deimosclient.ErrCodeRaftInternal, err.Error())
+	}
+
+	s.writeHeaders(w)
+	w.WriteHeader(errorStatus(apiErr.ErrorCode))
+	_ = json.NewEncoder(w).Encode(apiErr)
+}
+
+func (s *Server) writeHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	index := strconv.FormatUint(s.store.currentIndex(), 10)
+	w.Header().Set("X-Deimos-Index", index)
+	// There is no raft log behind the fake, every change is an entry of a
+	// single term.
+	w.Header().Set("X-Raft-Index", index)
+	w.Header().Set("X-Raft-Term", "1")
+}
+
+// errorStatus maps an error code to the HTTP status the server uses.
+func errorStatus(code int) int {
+	switch code {
+	case deimosclient.ErrCodeKeyNotFound:
+		return http.StatusNotFound
+	case deimosclient.ErrCodeNotFile, deimosclient.ErrCodeDirNotEmpty:
+		return http.StatusForbidden
+	case deimosclient.ErrCodeTestFailed, deimosclient.ErrCodeNodeExist:
+		return http.StatusPreconditionFailed
+	case deimosclient.ErrCodeRaftInternal, deimosclient.ErrCodeLeaderElect:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// parseForm merges the query string with the form encoded body, which the
+// client also sends with DELETE requests.
+func parseForm(r *http.Request) (url.Values, error) {
+	form := r.URL.Query()
+
+	if r.Body == nil {
+		return form, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		form[name] = value
+	}
+	return form, nil
+}
+
+func parseIndex(index string) (uint64, error) {
+	if index == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(index, 10, 64)
+}
@@ -0,0 +1,504 @@
+package deimostest
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+// node is a key or directory in the in-memory store.
+type node struct {
+	key           string
+	value         string
+	dir           bool
+	children      map[string]*node
+	parent        *node
+	createdIndex  uint64
+	modifiedIndex uint64
+	expiration    *time.Time
+}
+
+// event is a change recorded in the event history.
+type event struct {
+	action   string
+	node     *deimosclient.Node
+	prevNode *deimosclient.Node
+	// refresh events only extend a TTL and are not sent to watchers.
+	refresh bool
+}
+
+func (e *event) index() uint64 {
+	return e.node.ModifiedIndex
+}
+
+// store is an in-memory tree of nodes with an event history, following
+// the semantics of the deimos server.
+type store struct {
+	mu          sync.Mutex
+	root        *node
+	index       uint64
+	history     []*event
+	historySize int
+	changed     chan struct{}
+}
+
+func newStore(historySize int) *store {
+	return &store{
+		root:        &node{key: "/", dir: true, children: make(map[string]*node)},
+		historySize: historySize,
+		changed:     make(chan struct{}),
+	}
+}
+
+// request holds the parameters of a write.
+type request struct {
+	value     string
+	dir       bool
+	ttl       *time.Duration
+	prevExist string
+	prevValue string
+	prevIndex uint64
+	recursive bool
+	refresh   bool
+}
+
+func (r *request) compare() bool {
+	return r.prevValue != "" || r.prevIndex > 0
+}
+
+// currentIndex returns the index of the last change.
+func (s *store) currentIndex() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index
+}
+
+// get returns the node at key.
+func (s *store) get(key string, recursive, sorted bool) (*event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+
+	n := s.lookup(key)
+	if n == nil {
+		return nil, s.newError(deimosclient.ErrCodeKeyNotFound, key)
+	}
+	return &event{action: "get", node: n.export(recursive, sorted, true)}, nil
+}
+
+// set handles PUT, dispatching on the preconditions of the request.
+func (s *store) set(key string, req *request) (*event, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+
+	if key == "/" {
+		return nil, false, s.newError(deimosclient.ErrCodeRootROnly, key)
+	}
+
+	existing := s.lookup(key)
+
+	switch {
+	case req.refresh:
+		e, err := s.refreshLocked(key, existing, req)
+		return e, false, err
+	case req.compare():
+		e, err := s.compareAndSwapLocked(key, existing, req)
+		return e, false, err
+	case req.prevExist == "false":
+		if existing != nil {
+			return nil, false, s.newError(deimosclient.ErrCodeNodeExist, key)
+		}
+		e, err := s.createLocked(key, "create", req)
+		return e, true, err
+	case req.prevExist == "true":
+		if existing == nil {
+			return nil, false, s.newError(deimosclient.ErrCodeKeyNotFound, key)
+		}
+		e, err := s.updateLocked(existing, "update", req)
+		return e, false, err
+	default:
+		if existing != nil && existing.dir {
+			return nil, false, s.newError(deimosclient.ErrCodeNotFile, key)
+		}
+		e, err := s.createLocked(key, "set", req)
+		return e, existing == nil, err
+	}
+}
+
+// createInOrder handles POST, creating a key named after the next index
+// under the directory.
+func (s *store) createInOrder(dir string, req *request) (*event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+
+	if existing := s.lookup(dir); existing != nil && !existing.dir {
+		return nil, s.newError(deimosclient.ErrCodeNotDir, dir)
+	}
+
+	key := path.Join(dir, fmt.Sprintf("%020d", s.index+1))
+	return s.createLocked(key, "create", req)
+}
+
+// delete handles DELETE, with or without preconditions.
+func (s *store) delete(key string, req *request) (*event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+
+	if key == "/" {
+		return nil, s.newError(deimosclient.ErrCodeRootROnly, key)
+	}
+
+	existing := s.lookup(key)
+	if existing == nil {
+		return nil, s.newError(deimosclient.ErrCodeKeyNotFound, key)
+	}
+
+	action := "delete"
+	if req.compare() {
+		if existing.dir {
+			return nil, s.newError(deimosclient.ErrCodeNotFile, key)
+		}
+		if err := s.checkLocked(existing, req); err != nil {
+			return nil, err
+		}
+		action = "compareAndDelete"
+	} else if existing.dir {
+		if !req.dir && !req.recursive {
+			return nil, s.newError(deimosclient.ErrCodeNotFile, key)
+		}
+		if !req.recursive && len(existing.children) > 0 {
+			return nil, s.newError(deimosclient.ErrCodeDirNotEmpty, key)
+		}
+	}
+
+	return s.removeLocked(existing, action), nil
+}
+
+// watch blocks until an event on key with an index of at least waitIndex
+// is available, or the context is done. A zero waitIndex waits for the
+// next event.
+func (s *store) watch(ctx context.Context, key string, recursive bool, waitIndex uint64) (*event, error) {
+	s.mu.Lock()
+	if waitIndex == 0 {
+		waitIndex = s.index + 1
+	}
+	if len(s.history) > 0 && waitIndex < s.history[0].index() {
+		err := s.newError(deimosclient.ErrCodeEventIndexCleared, fmt.Sprintf("the requested history has been cleared [%d/%d]", s.history[0].index(), waitIndex))
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		for _, e := range s.history {
+			if e.index() >= waitIndex && !e.refresh && matches(e, key, recursive) {
+				s.mu.Unlock()
+				return e, nil
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// matches reports whether a watcher of key is interested in the event.
+// Deleting a directory also notifies the watchers of its children.
+func matches(e *event, key string, recursive bool) bool {
+	eventKey := e.node.Key
+	switch {
+	case eventKey == key:
+		return true
+	case recursive && (key == "/" || strings.HasPrefix(eventKey, key+"/")):
+		return true
+	case e.node.Dir && (e.action == "delete" || e.action == "expire"):
+		return eventKey == "/" || strings.HasPrefix(key, eventKey+"/")
+	}
+	return false
+}
+
+// expire removes the nodes whose TTL has passed.
+func (s *store) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked(now)
+}
+
+func (s *store) expireLocked(now time.Time) {
+	var expired []*node
+	s.root.walk(func(n *node) bool {
+		if n.expiration != nil && !n.expiration.After(now) {
+			expired = append(expired, n)
+			return false
+		}
+		return true
+	})
+
+	for _, n := range expired {
+		s.removeLocked(n, "expire")
+	}
+}
+
+func (s *store) compareAndSwapLocked(key string, existing *node, req *request) (*event, error) {
+	if existing == nil {
+		return nil, s.newError(deimosclient.ErrCodeKeyNotFound, key)
+	}
+	if existing.dir {
+		return nil, s.newError(deimosclient.ErrCodeNotFile, key)
+	}
+	if err := s.checkLocked(existing, req); err != nil {
+		return nil, err
+	}
+	return s.updateLocked(existing, "compareAndSwap", req)
+}
+
+// refreshLocked extends the TTL of an existing node, keeping its value.
+func (s *store) refreshLocked(key string, existing *node, req *request) (*event, error) {
+	if existing == nil {
+		return nil, s.newError(deimosclient.ErrCodeKeyNotFound, key)
+	}
+	if err := s.checkLocked(existing, req); err != nil {
+		return nil, err
+	}
+
+	prevNode := existing.export(false, false, false)
+
+	s.index++
+	existing.modifiedIndex = s.index
+	existing.setTTL(req.ttl)
+
+	action := "update"
+	if req.compare() {
+		action = "compareAndSwap"
+	}
+	return s.recordLocked(&event{action: action, node: existing.export(false, false, false), prevNode: prevNode, refresh: true}), nil
+}
+
+func (s *store) checkLocked(existing *node, req *request) error {
+	var causes []string
+	if req.prevValue != "" && req.prevValue != existing.value {
+		causes = append(causes, fmt.Sprintf("[%s != %s]", req.prevValue, existing.value))
+	}
+	if req.prevIndex > 0 && req.prevIndex != existing.modifiedIndex {
+		causes = append(causes, fmt.Sprintf("[%d != %d]", req.prevIndex, existing.modifiedIndex))
+	}
+	if len(causes) > 0 {
+		return s.newError(deimosclient.ErrCodeTestFailed, strings.Join(causes, " "))
+	}
+	return nil
+}
+
+// createLocked creates or replaces the node at key, creating missing
+// parent directories on the way.
+func (s *store) createLocked(key, action string, req *request) (*event, error) {
+	parent := s.root
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, name := range segments[:len(segments)-1] {
+		child, ok := parent.children[name]
+		if ok && !child.dir {
+			return nil, s.newError(deimosclient.ErrCodeNotDir, child.key)
+		}
+		if !ok {
+			child = &node{
+				key:           "/" + path.Join(segments[:i+1]...),
+				dir:           true,
+				children:      make(map[string]*node),
+				parent:        parent,
+				createdIndex:  s.index + 1,
+				modifiedIndex: s.index + 1,
+			}
+			parent.children[name] = child
+		}
+		parent = child
+	}
+
+	var prevNode *deimosclient.Node
+	name := segments[len(segments)-1]
+	if prev, ok := parent.children[name]; ok {
+		prevNode = prev.export(false, false, false)
+	}
+
+	s.index++
+	n := &node{
+		key:           key,
+		dir:           req.dir,
+		parent:        parent,
+		createdIndex:  s.index,
+		modifiedIndex: s.index,
+	}
+	if req.dir {
+		n.children = make(map[string]*node)
+	} else {
+		n.value = req.value
+	}
+	n.setTTL(req.ttl)
+	parent.children[name] = n
+
+	return s.recordLocked(&event{action: action, node: n.export(false, false, false), prevNode: prevNode}), nil
+}
+
+// updateLocked changes the value and TTL of an existing node in place.
+func (s *store) updateLocked(n *node, action string, req *request) (*event, error) {
+	if n.dir && !req.dir {
+		return nil, s.newError(deimosclient.ErrCodeNotFile, n.key)
+	}
+	if !n.dir && req.dir {
+		return nil, s.newError(deimosclient.ErrCodeNotDir, n.key)
+	}
+
+	prevNode := n.export(false, false, false)
+
+	s.index++
+	n.modifiedIndex = s.index
+	if !n.dir {
+		n.value = req.value
+	}
+	n.setTTL(req.ttl)
+
+	return s.recordLocked(&event{action: action, node: n.export(false, false, false), prevNode: prevNode}), nil
+}
+
+func (s *store) removeLocked(n *node, action string) *event {
+	prevNode := n.export(false, false, false)
+	delete(n.parent.children, path.Base(n.key))
+
+	s.index++
+	deleted := &deimosclient.Node{
+		Key:           n.key,
+		Dir:           n.dir,
+		ModifiedIndex: s.index,
+		CreatedIndex:  n.createdIndex,
+	}
+	return s.recordLocked(&event{action: action, node: deleted, prevNode: prevNode})
+}
+
+// recordLocked appends the event to the bounded history and wakes the
+// watchers.
+func (s *store) recordLocked(e *event) *event {
+	s.history = append(s.history, e)
+	if len(s.history) > s.historySize {
+		s.history = s.history[len(s.history)-s.historySize:]
+	}
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return e
+}
+
+func (s *store) lookup(key string) *node {
+	n := s.root
+	if key == "/" {
+		return n
+	}
+	for _, name := range strings.Split(strings.TrimPrefix(key, "/"), "/") {
+		if !n.dir {
+			return nil
+		}
+		child, ok := n.children[name]
+		if !ok {
+			return nil
+		}
+		n = child
+	}
+	return n
+}
+
+func (s *store) newError(code int, cause string) *deimosclient.APIError {
+	return &deimosclient.APIError{
+		ErrorCode: code,
+		Message:   errorMessages[code],
+		Cause:     cause,
+		Index:     s.index,
+	}
+}
+
+func (n *node) setTTL(ttl *time.Duration) {
+	if ttl == nil || *ttl <= 0 {
+		n.expiration = nil
+		return
+	}
+	expiration := time.Now().Add(*ttl)
+	n.expiration = &expiration
+}
+
+// walk calls fn for every node below n, descending into a node only if
+// fn returns true.
+func (n *node) walk(fn func(*node) bool) {
+	for _, child := range n.children {
+		if fn(child) && child.dir {
+			child.walk(fn)
+		}
+	}
+}
+
+// export converts the node into its client representation. The children
+// of a directory are listed when withChildren is set, recursively when
+// recursive is set.
+func (n *node) export(recursive, sorted, withChildren bool) *deimosclient.Node {
+	out := &deimosclient.Node{
+		Key:           n.key,
+		Value:         n.value,
+		Dir:           n.dir,
+		ModifiedIndex: n.modifiedIndex,
+		CreatedIndex:  n.createdIndex,
+	}
+	if n.key == "/" {
+		out.ModifiedIndex = 0
+		out.CreatedIndex = 0
+	}
+	if n.expiration != nil {
+		// The TTL is the remaining time, rounded up to the second.
+		expiration := *n.expiration
+		remaining := time.Until(expiration)
+		out.Expiration = &expiration
+		out.TTL = int64((remaining + time.Second - 1) / time.Second)
+	}
+
+	if n.dir && withChildren {
+		for _, child := range n.children {
+			out.Nodes = append(out.Nodes, child.export(recursive, sorted, recursive))
+		}
+		if sorted {
+			sort.Slice(out.Nodes, func(i, j int) bool {
+				return out.Nodes[i].Key < out.Nodes[j].Key
+			})
+		}
+	}
+	return out
+}
+
+var errorMessages = map[int]string{
+	deimosclient.ErrCodeKeyNotFound:        "Key not found",
+	deimosclient.ErrCodeTestFailed:         "Compare failed",
+	deimosclient.ErrCodeNotFile:            "Not a file",
+	deimosclient.ErrCodeNotDir:             "Not a directory",
+	deimosclient.ErrCodeNodeExist:          "Key already exists",
+	deimosclient.ErrCodeRootROnly:          "Root is read only",
+	deimosclient.ErrCodeDirNotEmpty:        "Directory not empty",
+	deimosclient.ErrCodeValueRequired:      "Value is Required in POST form",
+	deimosclient.ErrCodeTTLNaN:             "The given TTL in POST form is not a number",
+	deimosclient.ErrCodeIndexNaN:           "The given index in POST form is not a number",
+	deimosclient.ErrCodeInvalidField:       "Invalid field",
+	deimosclient.ErrCodeRefreshValue:       "Value provided on refresh",
+	deimosclient.ErrCodeRefreshTTLRequired: "A TTL must be provided on refresh",
+	deimosclient.ErrCodeEventIndexCleared:  "The event in requested index is outdated and cleared",
+}
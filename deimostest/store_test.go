@@ -0,0 +1,247 @@
+package deimostest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+func ttl(d time.Duration) *time.Duration {
+	return &d
+}
+
+// errorCode returns the error code of err, or 0 if it is not an APIError.
+func errorCode(err error) int {
+	var apiErr *deimosclient.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode
+	}
+	return 0
+}
+
+func TestStoreSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   bool
+		req        request
+		wantAction string
+		wantErr    int
+	}{
+		{name: "set new", req: request{value: "v"}, wantAction: "set"},
+		{name: "set existing", existing: true, req: request{value: "v"}, wantAction: "set"},
+		{name: "create new", req: request{value: "v", prevExist: "false"}, wantAction: "create"},
+		{name: "create existing", existing: true, req: request{value: "v", prevExist: "false"}, wantErr: deimosclient.ErrCodeNodeExist},
+		{name: "update existing", existing: true, req: request{value: "v", prevExist: "true"}, wantAction: "update"},
+		{name: "update missing", req: request{value: "v", prevExist: "true"}, wantErr: deimosclient.ErrCodeKeyNotFound},
+		{name: "cas value match", existing: true, req: request{value: "v", prevValue: "old"}, wantAction: "compareAndSwap"},
+		{name: "cas value mismatch", existing: true, req: request{value: "v", prevValue: "other"}, wantErr: deimosclient.ErrCodeTestFailed},
+		{name: "cas index mismatch", existing: true, req: request{value: "v", prevIndex: 42}, wantErr: deimosclient.ErrCodeTestFailed},
+		{name: "cas missing", req: request{value: "v", prevValue: "old"}, wantErr: deimosclient.ErrCodeKeyNotFound},
+		{name: "refresh existing", existing: true, req: request{refresh: true, ttl: ttl(time.Minute)}, wantAction: "update"},
+		{name: "refresh missing", req: request{refresh: true, ttl: ttl(time.Minute)}, wantErr: deimosclient.ErrCodeKeyNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(defaultHistorySize)
+			if tt.existing {
+				if _, _, err := s.set("/foo", &request{value: "old"}); err != nil {
+					t.Fatalf("set existing key: %v", err)
+				}
+			}
+
+			e, _, err := s.set("/foo", &tt.req)
+			if code := errorCode(err); code != tt.wantErr {
+				t.Fatalf("error code = %d (%v), want %d", code, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if e.action != tt.wantAction {
+				t.Errorf("action = %q, want %q", e.action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestStoreCompareAndDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     func(index uint64) request
+		wantErr int
+	}{
+		{name: "value match", req: func(uint64) request { return request{prevValue: "v"} }},
+		{name: "index match", req: func(index uint64) request { return request{prevIndex: index} }},
+		{name: "value mismatch", req: func(uint64) request { return request{prevValue: "other"} }, wantErr: deimosclient.ErrCodeTestFailed},
+		{name: "index mismatch", req: func(index uint64) request { return request{prevIndex: index + 1} }, wantErr: deimosclient.ErrCodeTestFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(defaultHistorySize)
+			created, _, err := s.set("/foo", &request{value: "v"})
+			if err != nil {
+				t.Fatalf("set: %v", err)
+			}
+
+			req := tt.req(created.index())
+			e, err := s.delete("/foo", &req)
+			if code := errorCode(err); code != tt.wantErr {
+				t.Fatalf("error code = %d (%v), want %d", code, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if e.action != "compareAndDelete" {
+				t.Errorf("action = %q, want compareAndDelete", e.action)
+			}
+			if _, err := s.get("/foo", false, false); errorCode(err) != deimosclient.ErrCodeKeyNotFound {
+				t.Errorf("get after delete: %v, want key not found", err)
+			}
+		})
+	}
+}
+
+func TestStoreDeleteDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     request
+		wantErr int
+	}{
+		{name: "without dir", req: request{}, wantErr: deimosclient.ErrCodeNotFile},
+		{name: "not empty", req: request{dir: true}, wantErr: deimosclient.ErrCodeDirNotEmpty},
+		{name: "recursive", req: request{recursive: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(defaultHistorySize)
+			if _, _, err := s.set("/dir/foo", &request{value: "v"}); err != nil {
+				t.Fatalf("set: %v", err)
+			}
+
+			_, err := s.delete("/dir", &tt.req)
+			if code := errorCode(err); code != tt.wantErr {
+				t.Fatalf("error code = %d (%v), want %d", code, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStoreCreateInOrder(t *testing.T) {
+	s := newStore(defaultHistorySize)
+
+	var keys []string
+	for range 3 {
+		e, err := s.createInOrder("/queue", &request{value: "v"})
+		if err != nil {
+			t.Fatalf("create in order: %v", err)
+		}
+		keys = append(keys, e.node.Key)
+	}
+
+	e, err := s.get("/queue", false, true)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(e.node.Nodes) != len(keys) {
+		t.Fatalf("got %d entries, want %d", len(e.node.Nodes), len(keys))
+	}
+	for i, node := range e.node.Nodes {
+		if node.Key != keys[i] {
+			t.Errorf("entry %d = %s, want %s", i, node.Key, keys[i])
+		}
+	}
+}
+
+func TestStoreExpire(t *testing.T) {
+	s := newStore(defaultHistorySize)
+	if _, _, err := s.set("/foo", &request{value: "v", ttl: ttl(time.Second)}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	e, err := s.get("/foo", false, false)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if e.node.Expiration == nil || e.node.TTL != 1 {
+		t.Errorf("expiration = %v, ttl = %d, want a one second TTL", e.node.Expiration, e.node.TTL)
+	}
+
+	s.expire(time.Now().Add(2 * time.Second))
+
+	if _, err := s.get("/foo", false, false); errorCode(err) != deimosclient.ErrCodeKeyNotFound {
+		t.Fatalf("get after expiry: %v, want key not found", err)
+	}
+	last := s.history[len(s.history)-1]
+	if last.action != "expire" || last.node.Key != "/foo" {
+		t.Errorf("last event = %s %s, want expire /foo", last.action, last.node.Key)
+	}
+}
+
+func TestStoreWatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		recursive bool
+		waitIndex uint64
+		wantKey   string
+		wantErr   int
+	}{
+		{name: "key", key: "/dir/b", wantKey: "/dir/b", waitIndex: 1},
+		{name: "recursive", key: "/dir", recursive: true, waitIndex: 1, wantKey: "/dir/a"},
+		{name: "from index", key: "/dir", recursive: true, waitIndex: 3, wantKey: "/dir/c"},
+		{name: "cleared", key: "/dir", recursive: true, waitIndex: 1, wantErr: deimosclient.ErrCodeEventIndexCleared},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			historySize := defaultHistorySize
+			if tt.wantErr == deimosclient.ErrCodeEventIndexCleared {
+				historySize = 1
+			}
+
+			s := newStore(historySize)
+			for _, key := range []string{"/dir/a", "/dir/b", "/dir/c"} {
+				if _, _, err := s.set(key, &request{value: "v"}); err != nil {
+					t.Fatalf("set %s: %v", key, err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			e, err := s.watch(ctx, tt.key, tt.recursive, tt.waitIndex)
+			if code := errorCode(err); code != tt.wantErr {
+				t.Fatalf("error code = %d (%v), want %d", code, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if e.node.Key != tt.wantKey {
+				t.Errorf("event key = %s, want %s", e.node.Key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestStoreWatchSkipsRefresh(t *testing.T) {
+	s := newStore(defaultHistorySize)
+	created, _, err := s.set("/foo", &request{value: "v", ttl: ttl(time.Minute)})
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, _, err := s.set("/foo", &request{refresh: true, ttl: ttl(time.Minute)}); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if e, err := s.watch(ctx, "/foo", false, created.index()+1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("watch = %v, %v, want no event for the refresh", e, err)
+	}
+}
@@ -25,7 +25,7 @@ func newDeleteOptions(options []DeleteOption) *DeleteOptions {
 func (c *Client) Delete(ctx context.Context, key string, opts ...DeleteOption) (*Response, error) {
 	deleteOpts := newDeleteOptions(opts)
 
-	URL := c.buildURL(key)
+	URL := c.buildLeaderURL(key)
 	query := url.Values{}
 
 	// Build the query string based on the options.
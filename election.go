@@ -0,0 +1,173 @@
+package deimosclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrNoLeader  = errors.New("election has no leader")
+	ErrNotLeader = errors.New("not the leader of this election")
+)
+
+// Election elects a single leader among candidates campaigning on the
+// same key.
+//
+// Candidates queue up under the election key like the contenders of a fair
+// lock, and the candidate with the oldest entry is the leader. The leader's
+// entry has a TTL and is renewed in the background; when it goes away for
+// any other reason than Resign, the leadership is lost and Done is closed
+// right away.
+type Election struct {
+	client *Client
+	key    string
+	opts   []LockOption
+
+	mu   sync.Mutex
+	term *DistributedLock
+}
+
+// NewElection creates a new election on the given key. It accepts the same
+// options as NewDistributedLock.
+func (c *Client) NewElection(key string, opts ...LockOption) *Election {
+	return &Election{
+		client: c,
+		key:    key,
+		opts:   append(slices.Clip(opts), WithFairness()),
+	}
+}
+
+// Campaign puts the candidate up for election and blocks until it is
+// elected or the context is cancelled. The value identifies the candidate
+// and is what Leader and Observe report.
+func (e *Election) Campaign(ctx context.Context, value string) error {
+	if e.IsLeader() {
+		return nil // Already the leader
+	}
+
+	l := e.client.NewDistributedLock(e.key, value, e.opts...)
+	if err := l.Lock(ctx); err != nil {
+		return fmt.Errorf("failed to campaign: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.term = l
+	return nil
+}
+
+// Resign gives up the leadership, letting the next candidate take over.
+func (e *Election) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.term == nil {
+		return ErrNotLeader
+	}
+
+	term := e.term
+	e.term = nil
+
+	// A lost leadership is given up already.
+	if err := term.Unlock(ctx); err != nil && !errors.Is(err, ErrLockNotHeld) && !IsKeyNotFound(err) {
+		return fmt.Errorf("failed to resign: %w", err)
+	}
+	return nil
+}
+
+// Done returns a channel that is closed when the current term of
+// leadership ends, whether because of Resign or because the leadership was
+// lost, e.g. after the entry of the leader expired. The channel is already
+// closed when not campaigning.
+func (e *Election) Done() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.term == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return e.term.Done()
+}
+
+// IsLeader reports whether the candidate is currently the leader.
+func (e *Election) IsLeader() bool {
+	select {
+	case <-e.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// Leader returns the value of the current leader, or ErrNoLeader if there
+// is none.
+func (e *Election) Leader(ctx context.Context) (string, error) {
+	leader, _, _, err := e.client.lockHolder(ctx, e.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to get leader: %w", err)
+	}
+	if leader == nil {
+		return "", ErrNoLeader
+	}
+	return leader.Value, nil
+}
+
+// Observe reports the value of the leader every time the leadership
+// changes, starting with the current leader. An empty value means that
+// there is no leader. The channel is closed when the context is done.
+func (e *Election) Observe(ctx context.Context) <-chan string {
+	leaders := make(chan string)
+	go e.observeLoop(ctx, leaders)
+	return leaders
+}
+
+func (e *Election) observeLoop(ctx context.Context, leaders chan<- string) {
+	defer close(leaders)
+
+	backoff := minReconnectBackoff
+	var last *Node
+	for first := true; ; first = false {
+		leader, _, index, err := e.client.lockHolder(ctx, e.key)
+		if err == nil && (first || !sameLeader(leader, last)) {
+			value := ""
+			if leader != nil {
+				value = leader.Value
+			}
+
+			select {
+			case leaders <- value:
+			case <-ctx.Done():
+				return
+			}
+			last = leader
+		}
+
+		if err == nil {
+			err = e.client.waitChange(ctx, e.key, index+1)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			e.client.logger.Warn("Observing election failed", "key", e.key, "backoff", backoff, "err", err)
+			if sleepContext(ctx, backoff) != nil {
+				return
+			}
+			backoff = min(backoff*2, maxReconnectBackoff)
+			continue
+		}
+		backoff = minReconnectBackoff
+	}
+}
+
+func sameLeader(a, b *Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Key == b.Key
+}
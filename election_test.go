@@ -0,0 +1,107 @@
+package deimosclient_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+func TestElectionHandover(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	first := client.NewElection("/election")
+	second := client.NewElection("/election")
+
+	if _, err := first.Leader(ctx); !errors.Is(err, deimosclient.ErrNoLeader) {
+		t.Fatalf("leader before campaign = %v, want ErrNoLeader", err)
+	}
+
+	if err := first.Campaign(ctx, "first"); err != nil {
+		t.Fatalf("campaign: %v", err)
+	}
+	result := acquireAsync(ctx, func(ctx context.Context) error { return second.Campaign(ctx, "second") })
+	requireWaiting(t, result)
+
+	if leader, err := second.Leader(ctx); err != nil || leader != "first" {
+		t.Fatalf("leader = %q, %v, want first", leader, err)
+	}
+	if !first.IsLeader() || second.IsLeader() {
+		t.Fatalf("IsLeader = %t, %t, want only the first candidate", first.IsLeader(), second.IsLeader())
+	}
+
+	if err := first.Resign(ctx); err != nil {
+		t.Fatalf("resign: %v", err)
+	}
+	requireAcquired(t, result)
+
+	if leader, err := first.Leader(ctx); err != nil || leader != "second" {
+		t.Fatalf("leader = %q, %v, want second", leader, err)
+	}
+	if err := first.Resign(ctx); !errors.Is(err, deimosclient.ErrNotLeader) {
+		t.Errorf("resign again = %v, want ErrNotLeader", err)
+	}
+}
+
+func TestElectionObserve(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	candidate := client.NewElection("/election")
+	leaders := client.NewElection("/election").Observe(ctx)
+
+	nextLeader := func() string {
+		t.Helper()
+		select {
+		case leader := <-leaders:
+			return leader
+		case <-time.After(5 * time.Second):
+			t.Fatal("no leader change observed")
+			return ""
+		}
+	}
+
+	if leader := nextLeader(); leader != "" {
+		t.Fatalf("initial leader = %q, want none", leader)
+	}
+
+	if err := candidate.Campaign(ctx, "candidate"); err != nil {
+		t.Fatalf("campaign: %v", err)
+	}
+	if leader := nextLeader(); leader != "candidate" {
+		t.Fatalf("leader = %q, want candidate", leader)
+	}
+
+	if err := candidate.Resign(ctx); err != nil {
+		t.Fatalf("resign: %v", err)
+	}
+	if leader := nextLeader(); leader != "" {
+		t.Fatalf("leader after resign = %q, want none", leader)
+	}
+}
+
+func TestElectionLostLeadership(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	e := client.NewElection("/election")
+	if err := e.Campaign(ctx, "leader"); err != nil {
+		t.Fatalf("campaign: %v", err)
+	}
+
+	if err := client.ForceUnlock(ctx, "/election", "leader"); err != nil {
+		t.Fatalf("force unlock: %v", err)
+	}
+
+	select {
+	case <-e.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done not closed after the leader entry was deleted")
+	}
+	if e.IsLeader() {
+		t.Error("still leader after losing the leadership")
+	}
+}
@@ -0,0 +1,104 @@
+package deimosclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes returned by the deimos server.
+const (
+	ErrCodeKeyNotFound = 100
+	ErrCodeTestFailed  = 101
+	ErrCodeNotFile     = 102
+	ErrCodeNotDir      = 104
+	ErrCodeNodeExist   = 105
+	ErrCodeRootROnly   = 107
+	ErrCodeDirNotEmpty = 108
+
+	ErrCodeValueRequired      = 200
+	ErrCodePrevValueRequired  = 201
+	ErrCodeTTLNaN             = 202
+	ErrCodeIndexNaN           = 203
+	ErrCodeInvalidField       = 209
+	ErrCodeInvalidForm        = 210
+	ErrCodeRefreshValue       = 211
+	ErrCodeRefreshTTLRequired = 212
+
+	ErrCodeRaftInternal = 300
+	ErrCodeLeaderElect  = 301
+
+	ErrCodeWatcherCleared    = 400
+	ErrCodeEventIndexCleared = 401
+)
+
+// APIError is returned when the deimos server rejects a request with an
+// error code.
+type APIError struct {
+	ErrorCode  int    `json:"errorCode"`
+	Message    string `json:"message"`
+	Cause      string `json:"cause,omitempty"`
+	Index      uint64 `json:"index"`
+	StatusCode int    `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	if e.Cause != "" {
+		return fmt.Sprintf("deimos API err: [%d] %s (%s)", e.ErrorCode, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("deimos API err: [%d] %s", e.ErrorCode, e.Message)
+}
+
+// IsKeyNotFound reports whether err is an APIError for a missing key.
+func IsKeyNotFound(err error) bool {
+	return hasErrorCode(err, ErrCodeKeyNotFound)
+}
+
+// IsCompareFailed reports whether err is an APIError for a failed
+// prevValue or prevIndex comparison.
+func IsCompareFailed(err error) bool {
+	return hasErrorCode(err, ErrCodeTestFailed)
+}
+
+// IsNodeExist reports whether err is an APIError for a key that already
+// exists.
+func IsNodeExist(err error) bool {
+	return hasErrorCode(err, ErrCodeNodeExist)
+}
+
+// IsNotFile reports whether err is an APIError for a key that is a
+// directory where a file was expected.
+func IsNotFile(err error) bool {
+	return hasErrorCode(err, ErrCodeNotFile)
+}
+
+// IsNotDir reports whether err is an APIError for a key that is not a
+// directory.
+func IsNotDir(err error) bool {
+	return hasErrorCode(err, ErrCodeNotDir)
+}
+
+// IsDirNotEmpty reports whether err is an APIError for deleting a
+// directory that still has children.
+func IsDirNotEmpty(err error) bool {
+	return hasErrorCode(err, ErrCodeDirNotEmpty)
+}
+
+// IsEventIndexCleared reports whether err is an APIError for a waitIndex
+// that fell out of the server's event history.
+func IsEventIndexCleared(err error) bool {
+	return hasErrorCode(err, ErrCodeEventIndexCleared)
+}
+
+// errorIndex returns the index carried by an APIError, or 0.
+func errorIndex(err error) uint64 {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Index
+	}
+	return 0
+}
+
+func hasErrorCode(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == code
+}
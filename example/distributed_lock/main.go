@@ -160,10 +160,7 @@ func autoRenewalExample(client *deimos.Client) {
 		log.Printf("   获取锁失败: %v", err)
 		return
 	}
-	fmt.Printf("   ✓ 成功获取锁\n")
-
-	// 启动自动续约
-	lock.StartAutoRenewal(ctx, renewalPeriod)
+	fmt.Printf("   ✓ 成功获取锁，自动续约已启动\n")
 
 	// 模拟长时间工作（超过原始TTL）
 	fmt.Println("   开始长时间工作（10秒）...")
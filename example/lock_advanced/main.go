@@ -2,10 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
@@ -119,11 +119,9 @@ func lockMonitoringExample(ctx context.Context, client *deimos.Client) TestResul
 		}
 	}
 
+	// 获取锁后自动续约已启动，Unlock 时停止
 	fmt.Printf("[监控] ✓ 获取锁成功\n")
 
-	// 启动自动续约
-	lock.StartAutoRenewal(ctx, 3*time.Second)
-
 	// 监控锁状态并执行工作
 	workSteps := 10
 	successSteps := 0
@@ -155,9 +153,9 @@ func lockMonitoringExample(ctx context.Context, client *deimos.Client) TestResul
 	}
 
 monitorWorkDone:
-	// 释放锁 - 如果锁已经过期，忽略 "Key not found" 错误
+	// 释放锁 - 如果锁已经过期，忽略锁已失效的错误
 	if err := lock.Unlock(ctx); err != nil {
-		if !isKeyNotFoundError(err) {
+		if !isLockGoneError(err) {
 			return TestResult{
 				TestName: testName,
 				Success:  false,
@@ -231,11 +229,11 @@ func lockTimeoutExample(ctx context.Context, client *deimos.Client) TestResult {
 	}
 
 timeoutWorkDone:
-	// 释放锁 - 如果锁已经过期，忽略 "Key not found" 错误
+	// 释放锁 - 如果锁已经过期，忽略锁已失效的错误
 	unlockErr := lock.Unlock(ctx)
 	if unlockErr != nil {
 		// 检查是否是锁已过期的错误
-		if !isKeyNotFoundError(unlockErr) {
+		if !isLockGoneError(unlockErr) {
 			return TestResult{
 				TestName: testName,
 				Success:  false,
@@ -335,7 +333,7 @@ multiLockWorkDone:
 		go func(lock *deimos.DistributedLock, index int) {
 			defer wg.Done()
 			if err := lock.Unlock(ctx); err != nil {
-				if !isKeyNotFoundError(err) {
+				if !isLockGoneError(err) {
 					log.Printf("[多锁] 释放锁 %d 失败: %v", index, err)
 					unlockErrors++
 				} else {
@@ -370,11 +368,8 @@ multiLockWorkDone:
 	}
 }
 
-// 检查错误是否是 "Key not found" 类型的错误
-func isKeyNotFoundError(err error) bool {
-	if err == nil {
-		return false
-	}
-	errStr := err.Error()
-	return strings.Contains(errStr, "Key not found") || strings.Contains(errStr, "HTTP 404")
+// 检查错误是否表示锁已失效：本地过期后返回 ErrLockNotHeld，
+// 服务端已删除时返回 "Key not found"
+func isLockGoneError(err error) bool {
+	return errors.Is(err, deimos.ErrLockNotHeld) || deimos.IsKeyNotFound(err)
 }
@@ -0,0 +1,162 @@
+package deimosclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// In fair mode the lock key is a directory holding a queue of contenders.
+// Each contender creates an in-order entry under it and holds the lock
+// once its entry is the oldest one. Instead of racing for the lock key
+// whenever it is released, a contender only watches the entry right
+// before its own, so the lock is handed over in FIFO order.
+
+// tryFairLockLocked enqueues an entry and keeps it only if it is first.
+func (l *DistributedLock) tryFairLockLocked(ctx context.Context) error {
+	entry, err := l.enqueue(ctx)
+	if err != nil {
+		return err
+	}
+
+	predecessor, found, err := l.predecessor(ctx, entry)
+	if err != nil || !found || predecessor != nil {
+		l.dequeue(ctx, entry)
+	}
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	case !found:
+		return fmt.Errorf("%w: queue entry %s expired", ErrLockNotAcquired, entry.Key)
+	case predecessor != nil:
+		return fmt.Errorf("%w: queued behind %s", ErrLockNotAcquired, predecessor.Key)
+	}
+
+	l.setHeldLocked(entry)
+	return nil
+}
+
+// fairLock enqueues an entry and waits for every entry before it to go
+// away.
+func (l *DistributedLock) fairLock(ctx context.Context) error {
+	entry, err := l.enqueue(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		predecessor, found, err := l.predecessor(ctx, entry)
+		if err != nil {
+			l.dequeue(ctx, entry)
+			return fmt.Errorf("failed to acquire lock: %w", err)
+		}
+
+		if !found {
+			// Our entry expired while waiting, get back in line.
+			if entry, err = l.enqueue(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if predecessor == nil {
+			l.mu.Lock()
+			l.setHeldLocked(entry)
+			l.mu.Unlock()
+			return nil
+		}
+
+		if err := l.waitForRelease(ctx, predecessor, entry); err != nil {
+			l.dequeue(ctx, entry)
+			return err
+		}
+	}
+}
+
+// enqueue creates our entry at the end of the queue.
+func (l *DistributedLock) enqueue(ctx context.Context) (*Node, error) {
+	resp, err := l.client.CreateInOrder(ctx, l.key, l.nodeValue(), WithCreateInOrderTTL(l.ttl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue lock entry: %w", err)
+	}
+	return resp.Node, nil
+}
+
+// dequeue removes our entry from the queue. It runs even if ctx is
+// cancelled, so that an abandoned entry does not block the queue until
+// it expires.
+func (l *DistributedLock) dequeue(ctx context.Context, entry *Node) {
+	if _, err := l.client.Delete(context.WithoutCancel(ctx), entry.Key); err != nil && !IsKeyNotFound(err) {
+		l.client.logger.Warn("Failed to dequeue lock entry", "key", entry.Key, "err", err)
+	}
+}
+
+// predecessor returns the entry right before ours, or nil if ours is
+// first. found is false if our entry is no longer in the queue. Otherwise
+// our entry is updated from the listing, so that its TTL is current.
+func (l *DistributedLock) predecessor(ctx context.Context, entry *Node) (predecessor *Node, found bool, err error) {
+	resp, err := l.client.Get(ctx, l.key, WithSorted())
+	if err != nil {
+		if IsKeyNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	for _, node := range resp.Node.Nodes {
+		if node.Key == entry.Key {
+			*entry = *node
+			return predecessor, true, nil
+		}
+		predecessor = node
+	}
+	return nil, false, nil
+}
+
+// waitForRelease watches the predecessor until it is deleted or expires.
+// Meanwhile our own entry is refreshed so that it does not expire while
+// we are waiting in line.
+func (l *DistributedLock) waitForRelease(ctx context.Context, predecessor, entry *Node) error {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Start right after the index we listed the predecessor at, so that a
+	// release in between is not missed.
+	events := l.client.Watch(watchCtx, predecessor.Key, WithWaitIndex(predecessor.ModifiedIndex+1))
+
+	var refresh <-chan time.Time
+	if l.ttl > 0 {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case resp, ok := <-events:
+			if !ok {
+				// The watch ended, look at the queue again.
+				return ctx.Err()
+			}
+			if isReleased(resp) {
+				return nil
+			}
+		case <-refresh:
+			// A refresh does not wake up the contender watching our entry.
+			resp, err := l.client.CompareAndSwap(ctx, entry.Key, entry.Value,
+				WithPrevIndex(entry.ModifiedIndex),
+				WithCasTTL(l.ttl),
+				WithCasRefresh())
+			if err != nil {
+				if IsKeyNotFound(err) || IsCompareFailed(err) {
+					// Our entry is gone, look at the queue again.
+					return nil
+				}
+				return fmt.Errorf("failed to refresh lock entry: %w", err)
+			}
+			*entry = *resp.Node
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package deimosclient_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+// waitFor polls cond until it holds, failing the test after a while.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitWaiters waits until the given number of contenders are queued
+// behind the holder of the lock at key.
+func waitWaiters(t *testing.T, client *deimosclient.Client, key string, waiters int) {
+	t.Helper()
+
+	waitFor(t, "lock waiters", func() bool {
+		status, err := client.LockStatus(context.Background(), key)
+		return err == nil && status.Waiters == waiters
+	})
+}
+
+// acquireAsync runs an acquisition in the background and reports the
+// result.
+func acquireAsync(ctx context.Context, acquire func(context.Context) error) <-chan error {
+	result := make(chan error, 1)
+	go func() { result <- acquire(ctx) }()
+	return result
+}
+
+func requireAcquired(t *testing.T, result <-chan error) {
+	t.Helper()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("lock: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock not acquired")
+	}
+}
+
+func requireWaiting(t *testing.T, result <-chan error) {
+	t.Helper()
+
+	select {
+	case err := <-result:
+		t.Fatalf("lock returned %v while it is held", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFairLockFIFO(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	first := client.NewDistributedLock("/lock", "first", deimosclient.WithFairness())
+	second := client.NewDistributedLock("/lock", "second", deimosclient.WithFairness())
+	third := client.NewDistributedLock("/lock", "third", deimosclient.WithFairness())
+
+	if err := first.Lock(ctx); err != nil {
+		t.Fatalf("lock first: %v", err)
+	}
+
+	secondResult := acquireAsync(ctx, second.Lock)
+	waitWaiters(t, client, "/lock", 1)
+	thirdResult := acquireAsync(ctx, third.Lock)
+	waitWaiters(t, client, "/lock", 2)
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("unlock first: %v", err)
+	}
+	requireAcquired(t, secondResult)
+	requireWaiting(t, thirdResult)
+
+	if err := second.Unlock(ctx); err != nil {
+		t.Fatalf("unlock second: %v", err)
+	}
+	requireAcquired(t, thirdResult)
+
+	if first.FencingToken() >= third.FencingToken() {
+		t.Errorf("fencing token did not grow: %d then %d", first.FencingToken(), third.FencingToken())
+	}
+}
+
+func TestFairLockTryLock(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	holder := client.NewDistributedLock("/lock", "holder", deimosclient.WithFairness())
+	other := client.NewDistributedLock("/lock", "other", deimosclient.WithFairness())
+
+	if err := holder.TryLock(ctx); err != nil {
+		t.Fatalf("try lock: %v", err)
+	}
+	if err := other.TryLock(ctx); !errors.Is(err, deimosclient.ErrLockNotAcquired) {
+		t.Fatalf("try lock while held = %v, want ErrLockNotAcquired", err)
+	}
+
+	// The failed attempt must not stay in the queue.
+	status, err := client.LockStatus(ctx, "/lock")
+	if err != nil {
+		t.Fatalf("lock status: %v", err)
+	}
+	if status.Holder != "holder" || status.Waiters != 0 {
+		t.Errorf("status = %+v, want held by holder without waiters", status)
+	}
+}
+
+func TestFairLockCancelledWaiterLeavesQueue(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	holder := client.NewDistributedLock("/lock", "holder", deimosclient.WithFairness())
+	waiter := client.NewDistributedLock("/lock", "waiter", deimosclient.WithFairness())
+
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	result := acquireAsync(waitCtx, waiter.Lock)
+	waitWaiters(t, client, "/lock", 1)
+	cancel()
+
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("lock = %v, want context.Canceled", err)
+	}
+	waitWaiters(t, client, "/lock", 0)
+}
+
+func TestFairLockWaiterRefreshIsSilent(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	holder := client.NewDistributedLock("/lock", "holder", deimosclient.WithFairness())
+	waiter := client.NewDistributedLock("/lock", "waiter",
+		deimosclient.WithFairness(),
+		deimosclient.WithTTL(time.Second),
+	)
+
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	result := acquireAsync(ctx, waiter.Lock)
+	entries := waitEntries(t, client, "/lock", 2)
+
+	// The next contender in line watches the waiting entry. Refreshing it
+	// must not look like a change.
+	w := client.NewWatcher(ctx, entries[1].Key, deimosclient.WithWaitIndex(entries[1].ModifiedIndex+1))
+	time.Sleep(2500 * time.Millisecond)
+	select {
+	case resp := <-w.Events():
+		t.Fatalf("event on the waiting entry: %s %+v", resp.Action, resp.Node)
+	default:
+	}
+
+	resp, err := client.Get(ctx, entries[1].Key)
+	if err != nil {
+		t.Fatalf("waiting entry expired: %v", err)
+	}
+	if resp.Node.Value != entries[1].Value {
+		t.Errorf("value = %q, want %q", resp.Node.Value, entries[1].Value)
+	}
+
+	if err := holder.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	requireAcquired(t, result)
+}
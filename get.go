@@ -9,6 +9,7 @@ import (
 
 type GetOptions struct {
 	recursive bool
+	sorted    bool
 }
 
 func newGetOptions(options []GetOption) *GetOptions {
@@ -31,6 +32,10 @@ func (c *Client) Get(ctx context.Context, key string, opts ...GetOption) (*Respo
 		query.Set("recursive", "true")
 	}
 
+	if getOpts.sorted {
+		query.Set("sorted", "true")
+	}
+
 	if len(query) > 0 {
 		URL += "?" + query.Encode()
 	}
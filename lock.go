@@ -9,38 +9,96 @@ import (
 )
 
 var (
-	ErrLockNotAcquired = errors.New("failed to acquire lock")
-	ErrLockNotHeld     = errors.New("lock is not held by this client")
-	ErrLockExpired     = errors.New("lock has expired")
+	ErrLockNotAcquired   = errors.New("failed to acquire lock")
+	ErrLockNotHeld       = errors.New("lock is not held by this client")
+	ErrLockExpired       = errors.New("lock has expired")
+	ErrStaleFencingToken = errors.New("fencing token is stale")
+	ErrLockLost          = errors.New("lock was lost")
+	ErrLockAlreadyHeld   = errors.New("lock is already held by this client")
+	// ErrInvalidRenewalPeriod is returned when acquiring a lock whose
+	// renewal period would not renew it before it expires.
+	ErrInvalidRenewalPeriod = errors.New("renewal period must be shorter than the lock TTL")
 )
 
 // DistributedLock represents a distributed lock
 type DistributedLock struct {
-	client    *Client
-	key       string
-	value     string
-	ttl       time.Duration
-	renewalCh chan struct{}
-	stopCh    chan struct{}
-	mu        sync.RWMutex
-	held      bool
+	client *Client
+	key    string
+	// value identifies the owner of the lock.
+	value string
+	// metadata stores the owner along with LockMetadata in the lock node.
+	metadata bool
+	ttl      time.Duration
+	// optsErr is the error of invalid options, returned when acquiring.
+	optsErr       error
+	autoRenewal   bool
+	renewalPeriod time.Duration
+	mu            sync.RWMutex
+	held          bool
+	// reentrant lets the holder acquire the lock again, counting holds so
+	// that only the outermost Unlock releases it.
+	reentrant bool
+	holds     int
 	lastIndex uint64
+	// token is the CreatedIndex of the lock node, which only grows from
+	// one acquisition to the next.
+	token uint64
+	// fair makes contenders queue up under the lock key, see fair_lock.go.
+	fair bool
+	// nodeKey is the key of the node backing the current hold: the lock
+	// key itself, or our queue entry under it in fair mode.
+	nodeKey string
+	// heldValue is the value of the node backing the current hold.
+	heldValue string
+	// done is closed when the current hold ends, and stopWatch stops
+	// watching its node.
+	done      chan struct{}
+	stopWatch context.CancelFunc
+	// expiry ends the current hold once its TTL has passed since it was
+	// last acquired or renewed, even if the server cannot be reached.
+	expiry *time.Timer
+	// stopRenewal stops the renewal of the current hold, and renewalDone
+	// is closed once it has stopped.
+	stopRenewal context.CancelFunc
+	renewalDone chan struct{}
 }
 
 // LockOptions contains options for creating a distributed lock
 type LockOptions struct {
-	ttl           time.Duration
+	ttl       time.Duration
+	fair      bool
+	metadata  bool
+	reentrant bool
+	// RenewalPeriod is how often a held lock is renewed. Zero means a
+	// third of the TTL.
 	RenewalPeriod time.Duration
-	AutoRenewal   bool
+	// AutoRenewal renews the lock in the background while it is held.
+	AutoRenewal bool
 }
 
 // DefaultLockOptions returns default lock options
 func DefaultLockOptions() *LockOptions {
 	return &LockOptions{
-		ttl:           30 * time.Second,
-		RenewalPeriod: 10 * time.Second,
-		AutoRenewal:   true,
+		ttl:         30 * time.Second,
+		AutoRenewal: true,
+	}
+}
+
+// renewalPeriod returns the configured renewal period, or a third of the
+// TTL if none is set.
+func (o *LockOptions) renewalPeriod() time.Duration {
+	if o.RenewalPeriod > 0 {
+		return o.RenewalPeriod
 	}
+	return o.ttl / 3
+}
+
+// validate checks that the lock is renewed before it expires.
+func (o *LockOptions) validate() error {
+	if o.AutoRenewal && o.ttl > 0 && o.RenewalPeriod >= o.ttl {
+		return fmt.Errorf("%w: %v >= %v", ErrInvalidRenewalPeriod, o.RenewalPeriod, o.ttl)
+	}
+	return nil
 }
 
 func newLockOptions(options []LockOption) *LockOptions {
@@ -51,17 +109,35 @@ func newLockOptions(options []LockOption) *LockOptions {
 	return opts
 }
 
-// NewDistributedLock creates a new distributed lock
+// NewDistributedLock creates a new distributed lock. The value identifies
+// the owner of the lock; a unique owner ID is generated if it is empty.
+// Holds are renewed and released by the index of their node, so locks
+// sharing a value cannot release each other's holds.
 func (c *Client) NewDistributedLock(key, value string, opts ...LockOption) *DistributedLock {
 	lockOpts := newLockOptions(opts)
 
+	if value == "" {
+		value = newOwnerID()
+	}
+
+	done := make(chan struct{})
+	close(done)
+
 	return &DistributedLock{
-		client:    c,
-		key:       key,
-		value:     value,
-		ttl:       lockOpts.ttl,
-		renewalCh: make(chan struct{}),
-		stopCh:    make(chan struct{}),
+		client:        c,
+		key:           key,
+		value:         value,
+		ttl:           lockOpts.ttl,
+		fair:          lockOpts.fair,
+		metadata:      lockOpts.metadata,
+		reentrant:     lockOpts.reentrant,
+		optsErr:       lockOpts.validate(),
+		autoRenewal:   lockOpts.AutoRenewal && lockOpts.ttl > 0,
+		renewalPeriod: lockOpts.renewalPeriod(),
+		done:          done,
+		stopWatch:     func() {},
+		stopRenewal:   func() {},
+		renewalDone:   done,
 	}
 }
 
@@ -71,22 +147,154 @@ func (l *DistributedLock) TryLock(ctx context.Context) error {
 	defer l.mu.Unlock()
 
 	if l.held {
-		return nil // Already holding the lock
+		return l.reenterLocked()
+	}
+	if l.optsErr != nil {
+		return l.optsErr
+	}
+
+	if l.fair {
+		return l.tryFairLockLocked(ctx)
 	}
 
 	// Try to create the lock key only if it doesn't exist (atomic create)
-	resp, err := l.client.Set(ctx, l.key, l.value, WithTTL(l.ttl), WithPrevExist(false))
+	resp, err := l.client.Set(ctx, l.key, l.nodeValue(), WithTTL(l.ttl), WithPrevExist(false))
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrLockNotAcquired, err)
+		if IsNodeExist(err) {
+			return fmt.Errorf("%w: %w", ErrLockNotAcquired, err)
+		}
+		return fmt.Errorf("failed to acquire lock: %w", err)
 	}
 
-	l.held = true
-	l.lastIndex = resp.Node.ModifiedIndex
+	l.setHeldLocked(resp.Node)
 	return nil
 }
 
+// reenterLocked acquires the lock again while already holding it, which
+// is only allowed in reentrant mode.
+func (l *DistributedLock) reenterLocked() error {
+	if !l.reentrant {
+		return ErrLockAlreadyHeld
+	}
+	l.holds++
+	return nil
+}
+
+// adopt makes the lock hold a node created on its behalf by another
+// primitive, so that it can be renewed and released like any lock.
+func (l *DistributedLock) adopt(node *Node) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setHeldLocked(node)
+}
+
+// setHeldLocked records the node backing a successful acquisition and
+// starts watching it, so that losing the lock is noticed right away. The
+// lock is renewed in the background if auto-renewal is enabled.
+func (l *DistributedLock) setHeldLocked(node *Node) {
+	l.held = true
+	l.holds = 1
+	l.nodeKey = node.Key
+	l.heldValue = node.Value
+	l.lastIndex = node.ModifiedIndex
+	l.token = node.CreatedIndex
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	l.done = make(chan struct{})
+	l.stopWatch = stopWatch
+	go l.watchHold(watchCtx, node, l.done)
+
+	if ttl := l.holdTTL(node); ttl > 0 {
+		done := l.done
+		l.expiry = time.AfterFunc(ttl, func() { l.lost(done, "reason", "expired") })
+	}
+
+	if l.autoRenewal {
+		l.startRenewalLocked(context.Background(), l.renewalPeriod)
+	}
+}
+
+// clearHeldLocked ends the current hold.
+func (l *DistributedLock) clearHeldLocked() {
+	if !l.held {
+		return
+	}
+
+	l.held = false
+	l.holds = 0
+	l.nodeKey = ""
+	l.heldValue = ""
+	l.lastIndex = 0
+	l.token = 0
+
+	l.stopWatch()
+	l.stopRenewal()
+	if l.expiry != nil {
+		l.expiry.Stop()
+		l.expiry = nil
+	}
+	close(l.done)
+}
+
+// holdTTL returns how long the node backing a hold lives unless renewed,
+// from the TTL the server reported for it. The node must be fresh: a fair
+// lock re-reads its entry when it takes the hold, since the entry may have
+// been refreshed a while before.
+func (l *DistributedLock) holdTTL(node *Node) time.Duration {
+	if node.TTL > 0 {
+		return min(l.ttl, time.Duration(node.TTL)*time.Second)
+	}
+	return l.ttl
+}
+
+// watchHold watches the node backing a hold and ends the hold when the
+// node is deleted or expires. A partitioned client does not see the
+// expiry, which is why the hold also ends on the local expiry timer.
+func (l *DistributedLock) watchHold(ctx context.Context, node *Node, done chan struct{}) {
+	w := l.client.NewWatcher(ctx, node.Key, WithWaitIndex(node.ModifiedIndex+1), WithReconnect())
+	for resp := range w.Events() {
+		// After a resync, the key may be held by someone else by now.
+		if isReleased(resp) || (resp.Action == ActionResync && resp.Node.CreatedIndex != node.CreatedIndex) {
+			l.lost(done, "action", resp.Action)
+			return
+		}
+	}
+
+	if ctx.Err() == nil {
+		// We can no longer tell whether we still hold the lock.
+		l.lost(done, "err", w.Err())
+	}
+}
+
+// lost ends the hold identified by done, if it is still the current one.
+func (l *DistributedLock) lost(done chan struct{}, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.held || l.done != done {
+		return
+	}
+	l.client.logger.Warn("Lock lost", append([]any{"key", l.key, "node", l.nodeKey}, args...)...)
+	l.clearHeldLocked()
+}
+
 // Lock acquires the lock, blocking until successful or context is cancelled
 func (l *DistributedLock) Lock(ctx context.Context) error {
+	l.mu.Lock()
+	if l.held {
+		defer l.mu.Unlock()
+		return l.reenterLocked()
+	}
+	l.mu.Unlock()
+
+	if l.optsErr != nil {
+		return l.optsErr
+	}
+
+	if l.fair {
+		return l.fairLock(ctx)
+	}
+
 	// First, try to acquire the lock directly.
 	err := l.TryLock(ctx)
 	if err == nil {
@@ -109,7 +317,7 @@ func (l *DistributedLock) watchAndLock(ctx context.Context) error {
 
 		// Wait for a DELETE event on the lock key.
 		for resp := range watchChan {
-			if resp.Action == "delete" || resp.Action == "compareAndDelete" || resp.Action == "expire" {
+			if isReleased(resp) {
 				// The lock seems to have been released. Try to acquire it.
 				err := l.TryLock(ctx)
 				if err == nil {
@@ -136,29 +344,46 @@ func (l *DistributedLock) watchAndLock(ctx context.Context) error {
 	}
 }
 
-// Unlock releases the lock
+// isReleased reports whether a watch event means the watched lock node is
+// gone.
+func isReleased(resp *Response) bool {
+	switch resp.Action {
+	case "delete", "compareAndDelete", "expire":
+		return true
+	case ActionResync:
+		return resp.Node == nil
+	}
+	return false
+}
+
+// Unlock releases the lock and stops its renewal. In reentrant mode, only
+// the Unlock matching the outermost acquisition releases it.
 func (l *DistributedLock) Unlock(ctx context.Context) error {
 	l.mu.Lock()
-	defer l.mu.Unlock()
 
 	if !l.held {
+		l.mu.Unlock()
 		return ErrLockNotHeld
 	}
-
-	// Stop auto-renewal if running
-	select {
-	case l.stopCh <- struct{}{}:
-	default:
+	if l.holds > 1 {
+		l.holds--
+		l.mu.Unlock()
+		return nil
 	}
 
 	// Use compare-and-delete to ensure we only delete our own lock
-	_, err := l.client.CompareAndDelete(ctx, l.key, WithPrevValue(l.value))
+	_, err := l.client.CompareAndDelete(ctx, l.nodeKey, WithPrevIndex(l.lastIndex))
 	if err != nil {
+		l.mu.Unlock()
 		return fmt.Errorf("failed to release lock: %w", err)
 	}
 
-	l.held = false
-	l.lastIndex = 0
+	renewalDone := l.renewalDone
+	l.clearHeldLocked()
+	l.mu.Unlock()
+
+	// The renewal may be waiting for the mutex, let it see the lock is gone.
+	<-renewalDone
 	return nil
 }
 
@@ -169,7 +394,78 @@ func (l *DistributedLock) IsHeld() bool {
 	return l.held
 }
 
-// Renew extends the lock's TTL
+// Done returns a channel that is closed when the current hold of the lock
+// ends: on Unlock, or as soon as the lock is lost because renewing it
+// failed, it expired or it was deleted by someone else. The channel is
+// already closed when the lock is not held.
+func (l *DistributedLock) Done() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.done
+}
+
+// holdKey returns the key of the node backing the current hold, or "" if
+// the lock is not held.
+func (l *DistributedLock) holdKey() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.nodeKey
+}
+
+// FencingToken returns the fencing token of the current hold, or 0 if the
+// lock is not held. Tokens increase with every acquisition of the lock, so
+// downstream storage can reject writes carrying a token older than the
+// newest one it has seen, or check it with Client.ValidateFencingToken.
+func (l *DistributedLock) FencingToken() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.token
+}
+
+// ValidateFencingToken checks that the token still belongs to the current
+// holder of the lock at key. It returns ErrStaleFencingToken if the lock
+// has been released, has expired or was acquired by someone else since.
+func (c *Client) ValidateFencingToken(ctx context.Context, key string, token uint64) error {
+	holder, _, _, err := c.lockHolder(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to validate fencing token: %w", err)
+	}
+
+	if holder == nil {
+		return fmt.Errorf("%w: lock is not held", ErrStaleFencingToken)
+	}
+	if holder.CreatedIndex != token {
+		return fmt.Errorf("%w: current token is %d", ErrStaleFencingToken, holder.CreatedIndex)
+	}
+	return nil
+}
+
+// lockHolder returns the node of the current holder of the lock at key,
+// or nil if the lock is free. The holder of a fair lock is the oldest
+// entry of its queue, and waiters is the number of entries behind it.
+// index is the index the lock was read at.
+func (c *Client) lockHolder(ctx context.Context, key string) (holder *Node, waiters int, index uint64, err error) {
+	resp, err := c.Get(ctx, key, WithSorted())
+	if err != nil {
+		if IsKeyNotFound(err) {
+			return nil, 0, errorIndex(err), nil
+		}
+		return nil, 0, 0, err
+	}
+
+	index = observedIndex(resp)
+	if !resp.Node.Dir {
+		return resp.Node, 0, index, nil
+	}
+	if len(resp.Node.Nodes) == 0 {
+		return nil, 0, index, nil
+	}
+	return resp.Node.Nodes[0], len(resp.Node.Nodes) - 1, index, nil
+}
+
+// Renew extends the lock's TTL. If the server no longer has the hold, the
+// lock is lost and ErrLockExpired is returned. Other errors leave the hold
+// in place, to be renewed again or to expire locally.
 func (l *DistributedLock) Renew(ctx context.Context) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -179,25 +475,64 @@ func (l *DistributedLock) Renew(ctx context.Context) error {
 	}
 
 	// Use compare-and-swap to renew the lock
-	resp, err := l.client.CompareAndSwap(ctx, l.key, l.value,
-		WithPrevValue(l.value),
+	resp, err := l.client.CompareAndSwap(ctx, l.nodeKey, l.heldValue,
+		WithPrevIndex(l.lastIndex),
 		WithCasTTL(l.ttl))
 	if err != nil {
-		l.held = false
-		return fmt.Errorf("%w: %v", ErrLockExpired, err)
+		if ctx.Err() != nil {
+			// The renewal was called off, not refused.
+			return err
+		}
+		if !IsKeyNotFound(err) && !IsCompareFailed(err) {
+			// The hold may well be alive on the server. Keep it until a
+			// later renewal tells, or the local expiry ends it.
+			return fmt.Errorf("failed to renew lock: %w", err)
+		}
+		l.client.logger.Warn("Lock lost", "key", l.key, "node", l.nodeKey, "err", err)
+		l.clearHeldLocked()
+		return fmt.Errorf("%w: %w", ErrLockExpired, err)
 	}
 
 	l.lastIndex = resp.Node.ModifiedIndex
+	if l.expiry != nil {
+		l.expiry.Reset(l.holdTTL(resp.Node))
+	}
 	return nil
 }
 
-// StartAutoRenewal starts automatic lock renewal in the background
+// StartAutoRenewal renews the current hold of the lock in the background
+// with the given period, until the context is done or the hold ends. It
+// replaces the renewal started when acquiring the lock, if any.
 func (l *DistributedLock) StartAutoRenewal(ctx context.Context, renewalPeriod time.Duration) {
-	go l.autoRenewalLoop(ctx, renewalPeriod)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.startRenewalLocked(ctx, renewalPeriod)
+}
+
+// StopAutoRenewal stops renewing the lock and waits for the renewal to
+// stop. The lock stays held until it is unlocked or expires.
+func (l *DistributedLock) StopAutoRenewal() {
+	l.mu.Lock()
+	renewalDone := l.renewalDone
+	l.stopRenewal()
+	l.mu.Unlock()
+
+	<-renewalDone
+}
+
+func (l *DistributedLock) startRenewalLocked(ctx context.Context, renewalPeriod time.Duration) {
+	l.stopRenewal()
+
+	renewalCtx, stopRenewal := context.WithCancel(ctx)
+	l.stopRenewal = stopRenewal
+	l.renewalDone = make(chan struct{})
+	go l.autoRenewalLoop(renewalCtx, renewalPeriod, l.renewalDone)
 }
 
 // autoRenewalLoop runs the automatic renewal process
-func (l *DistributedLock) autoRenewalLoop(ctx context.Context, renewalPeriod time.Duration) {
+func (l *DistributedLock) autoRenewalLoop(ctx context.Context, renewalPeriod time.Duration, done chan struct{}) {
+	defer close(done)
+
 	ticker := time.NewTicker(renewalPeriod)
 	defer ticker.Stop()
 
@@ -205,16 +540,16 @@ func (l *DistributedLock) autoRenewalLoop(ctx context.Context, renewalPeriod tim
 		select {
 		case <-ctx.Done():
 			return
-		case <-l.stopCh:
-			return
 		case <-ticker.C:
-			if l.IsHeld() {
-				if err := l.Renew(ctx); err != nil {
-					// Log error but continue trying
-					// In a real implementation, you might want to use a proper logger
-					fmt.Printf("Failed to renew lock %s: %v\n", l.key, err)
-				}
+			err := l.Renew(ctx)
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			if errors.Is(err, ErrLockNotHeld) || errors.Is(err, ErrLockExpired) {
+				// The hold ended, there is nothing left to renew.
+				return
 			}
+			l.client.logger.Warn("Failed to renew lock, retrying", "key", l.key, "err", err)
 		}
 	}
 }
@@ -226,20 +561,62 @@ func (l *DistributedLock) WithLock(ctx context.Context, fn func() error) error {
 	}
 	defer func() {
 		if unlockErr := l.Unlock(ctx); unlockErr != nil {
-			fmt.Printf("Failed to unlock: %v\n", unlockErr)
+			l.client.logger.Warn("Failed to unlock", "key", l.key, "err", unlockErr)
 		}
 	}()
 
 	return fn()
 }
 
+// WithLockContext executes a function while holding the lock, like
+// WithLock. The context passed to fn is cancelled as soon as the lock is
+// lost, with ErrLockLost as its cause, so that the work can be aborted.
+// If the lock was lost before fn returned, the returned error wraps
+// ErrLockLost along with the error of fn, if any.
+func (l *DistributedLock) WithLockContext(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := l.Lock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := l.Unlock(ctx); unlockErr != nil {
+			l.client.logger.Warn("Failed to unlock", "key", l.key, "err", unlockErr)
+		}
+	}()
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	done := l.Done()
+	go func() {
+		select {
+		case <-done:
+			cancel(ErrLockLost)
+		case <-lockCtx.Done():
+		}
+	}()
+
+	err := fn(lockCtx)
+
+	select {
+	case <-done:
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrLockLost, err)
+		}
+		return ErrLockLost
+	default:
+		return err
+	}
+}
+
 // LockInfo returns information about the current lock state
 type LockInfo struct {
-	Key       string
-	Value     string
-	Held      bool
-	LastIndex uint64
-	TTL       time.Duration
+	Key          string
+	Value        string
+	Held         bool
+	Holds        int
+	LastIndex    uint64
+	FencingToken uint64
+	TTL          time.Duration
 }
 
 // Info returns information about the lock
@@ -248,10 +625,12 @@ func (l *DistributedLock) Info() LockInfo {
 	defer l.mu.RUnlock()
 
 	return LockInfo{
-		Key:       l.key,
-		Value:     l.value,
-		Held:      l.held,
-		LastIndex: l.lastIndex,
-		TTL:       l.ttl,
+		Key:          l.key,
+		Value:        l.value,
+		Held:         l.held,
+		Holds:        l.holds,
+		LastIndex:    l.lastIndex,
+		FencingToken: l.token,
+		TTL:          l.ttl,
 	}
 }
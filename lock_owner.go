@@ -0,0 +1,56 @@
+package deimosclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LockMetadata describes the holder of a lock created with
+// WithLockMetadata. It is stored as JSON in the value of the lock node.
+type LockMetadata struct {
+	Owner      string    `json:"owner"`
+	Host       string    `json:"host,omitempty"`
+	PID        int       `json:"pid"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+}
+
+// ParseLockMetadata decodes the value of a lock node created with
+// WithLockMetadata.
+func ParseLockMetadata(value string) (*LockMetadata, error) {
+	var metadata LockMetadata
+	if err := json.Unmarshal([]byte(value), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse lock metadata: %w", err)
+	}
+	return &metadata, nil
+}
+
+// newOwnerID returns a random ID identifying the owner of a lock.
+func newOwnerID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// nodeValue returns the value to store in the node of a new hold: the
+// owner of the lock, wrapped in metadata if enabled.
+func (l *DistributedLock) nodeValue() string {
+	if !l.metadata {
+		return l.value
+	}
+
+	host, _ := os.Hostname()
+	value, err := json.Marshal(LockMetadata{
+		Owner:      l.value,
+		Host:       host,
+		PID:        os.Getpid(),
+		AcquiredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return l.value
+	}
+	return string(value)
+}
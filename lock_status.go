@@ -0,0 +1,106 @@
+package deimosclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLockHolderMismatch is returned by ForceUnlock when the lock is not
+// held by the expected holder.
+var ErrLockHolderMismatch = errors.New("lock is not held by the expected holder")
+
+// forceUnlockAttempts bounds the attempts of ForceUnlock to delete a hold
+// that is being renewed.
+const forceUnlockAttempts = 3
+
+// LockStatus describes the current holder of a lock, as seen by anyone.
+type LockStatus struct {
+	Key  string
+	Held bool
+	// Holder is the value of the holder's node, see LockMetadata.
+	Holder string
+	// NodeKey is the key of the holder's node: the lock key itself, or
+	// the holder's queue entry for a fair lock.
+	NodeKey       string
+	CreatedIndex  uint64
+	ModifiedIndex uint64
+	// TTL is the time left before the hold expires, unless renewed. It is
+	// zero if the hold does not expire.
+	TTL        time.Duration
+	Expiration *time.Time
+	// Waiters is the number of contenders queued behind the holder of a
+	// fair lock.
+	Waiters int
+}
+
+// LockStatus returns the current holder of the lock at key. Held is false
+// if the lock is free.
+func (c *Client) LockStatus(ctx context.Context, key string) (*LockStatus, error) {
+	holder, waiters, _, err := c.lockHolder(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock status: %w", err)
+	}
+
+	status := &LockStatus{Key: key}
+	if holder == nil {
+		return status, nil
+	}
+
+	status.Held = true
+	status.Waiters = waiters
+	status.Holder = holder.Value
+	status.NodeKey = holder.Key
+	status.CreatedIndex = holder.CreatedIndex
+	status.ModifiedIndex = holder.ModifiedIndex
+	status.Expiration = holder.Expiration
+	status.TTL = holder.Remaining()
+	return status, nil
+}
+
+// ForceUnlock breaks the lock at key if it is held by expectedHolder,
+// which is matched against the value of the holder's node, or against the
+// owner in its LockMetadata. The holder's node is deleted with a
+// compare-and-delete on its index, so a lock acquired again in the
+// meantime is never broken. It returns ErrLockHolderMismatch if the lock
+// is free or held by someone else.
+func (c *Client) ForceUnlock(ctx context.Context, key, expectedHolder string) error {
+	var createdIndex uint64
+	for attempt := 1; ; attempt++ {
+		status, err := c.LockStatus(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to force unlock: %w", err)
+		}
+		if !status.Held {
+			return fmt.Errorf("%w: lock is free", ErrLockHolderMismatch)
+		}
+		if !isHolder(status.Holder, expectedHolder) || (createdIndex != 0 && status.CreatedIndex != createdIndex) {
+			return fmt.Errorf("%w: held by %q", ErrLockHolderMismatch, status.Holder)
+		}
+
+		_, err = c.CompareAndDelete(ctx, status.NodeKey, WithPrevIndex(status.ModifiedIndex))
+		if err == nil {
+			return nil
+		}
+		if !IsKeyNotFound(err) && !IsCompareFailed(err) {
+			return fmt.Errorf("failed to force unlock: %w", err)
+		}
+		if attempt >= forceUnlockAttempts {
+			return fmt.Errorf("%w: lock keeps changing: %w", ErrLockHolderMismatch, err)
+		}
+
+		// The holder may just have renewed the hold, look again but only
+		// break the same hold.
+		createdIndex = status.CreatedIndex
+	}
+}
+
+// isHolder reports whether the value of a lock node belongs to holder.
+func isHolder(value, holder string) bool {
+	if value == holder {
+		return true
+	}
+	metadata, err := ParseLockMetadata(value)
+	return err == nil && metadata.Owner != "" && metadata.Owner == holder
+}
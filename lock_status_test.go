@@ -0,0 +1,73 @@
+package deimosclient_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+func TestLockStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []deimosclient.LockOption
+	}{
+		{name: "plain"},
+		{name: "fair", opts: []deimosclient.LockOption{deimosclient.WithFairness()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestClient(t)
+			ctx := testContext(t)
+
+			status, err := client.LockStatus(ctx, "/lock")
+			if err != nil || status.Held {
+				t.Fatalf("status of a free lock = %+v, %v", status, err)
+			}
+
+			lock := client.NewDistributedLock("/lock", "holder", tt.opts...)
+			if err := lock.Lock(ctx); err != nil {
+				t.Fatalf("lock: %v", err)
+			}
+
+			status, err = client.LockStatus(ctx, "/lock")
+			if err != nil {
+				t.Fatalf("lock status: %v", err)
+			}
+			if !status.Held || status.Holder != "holder" || status.CreatedIndex != lock.FencingToken() {
+				t.Errorf("status = %+v, want held by holder with token %d", status, lock.FencingToken())
+			}
+			if status.TTL <= 0 || status.TTL > 30*time.Second {
+				t.Errorf("TTL = %v, want up to the lock TTL", status.TTL)
+			}
+		})
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	if err := client.ForceUnlock(ctx, "/lock", "holder"); !errors.Is(err, deimosclient.ErrLockHolderMismatch) {
+		t.Fatalf("force unlock of a free lock = %v, want ErrLockHolderMismatch", err)
+	}
+
+	lock := client.NewDistributedLock("/lock", "holder")
+	if err := lock.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	if err := client.ForceUnlock(ctx, "/lock", "someone-else"); !errors.Is(err, deimosclient.ErrLockHolderMismatch) {
+		t.Fatalf("force unlock of another holder = %v, want ErrLockHolderMismatch", err)
+	}
+	if err := client.ForceUnlock(ctx, "/lock", "holder"); err != nil {
+		t.Fatalf("force unlock: %v", err)
+	}
+
+	status, err := client.LockStatus(ctx, "/lock")
+	if err != nil || status.Held {
+		t.Errorf("status after force unlock = %+v, %v, want free", status, err)
+	}
+}
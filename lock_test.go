@@ -0,0 +1,342 @@
+package deimosclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+// partition is a transport that fails every request while cut.
+type partition struct {
+	cut atomic.Bool
+}
+
+func (p *partition) RoundTrip(req *http.Request) (*http.Response, error) {
+	if p.cut.Load() {
+		return nil, errors.New("network partition")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func requireDone(t *testing.T, done <-chan struct{}, within time.Duration) {
+	t.Helper()
+
+	select {
+	case <-done:
+	case <-time.After(within):
+		t.Fatal("hold did not end")
+	}
+}
+
+func TestLockLostOnDelete(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	lock := client.NewDistributedLock("/lock", "holder")
+
+	err := lock.WithLockContext(ctx, func(ctx context.Context) error {
+		if err := client.ForceUnlock(ctx, "/lock", "holder"); err != nil {
+			t.Errorf("force unlock: %v", err)
+		}
+		<-ctx.Done()
+		if cause := context.Cause(ctx); !errors.Is(cause, deimosclient.ErrLockLost) {
+			t.Errorf("cause = %v, want ErrLockLost", cause)
+		}
+		return ctx.Err()
+	})
+	if !errors.Is(err, deimosclient.ErrLockLost) {
+		t.Fatalf("WithLockContext = %v, want ErrLockLost", err)
+	}
+	if lock.IsHeld() {
+		t.Error("lock still held after it was lost")
+	}
+}
+
+func TestLockLostOnExpiryDuringPartition(t *testing.T) {
+	srv, _ := newTestClient(t)
+	ctx := testContext(t)
+
+	p := &partition{}
+	client := srv.Client(deimosclient.WithTransport(p))
+	lock := client.NewDistributedLock("/lock", "holder",
+		deimosclient.WithTTL(time.Second),
+		deimosclient.WithAutoRenewal(false),
+	)
+
+	if err := lock.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	p.cut.Store(true)
+
+	requireDone(t, lock.Done(), 3*time.Second)
+	if lock.IsHeld() {
+		t.Error("lock still held after its TTL passed")
+	}
+}
+
+func TestUnlockAfterExpiry(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	lock := client.NewDistributedLock("/lock", "holder",
+		deimosclient.WithTTL(time.Second),
+		deimosclient.WithAutoRenewal(false),
+	)
+	if err := lock.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	requireDone(t, lock.Done(), 3*time.Second)
+
+	if err := lock.Unlock(ctx); !errors.Is(err, deimosclient.ErrLockNotHeld) {
+		t.Fatalf("unlock after expiry = %v, want ErrLockNotHeld", err)
+	}
+
+	// The expired lock is free for the next holder.
+	next := client.NewDistributedLock("/lock", "next")
+	if err := next.TryLock(ctx); err != nil {
+		t.Errorf("try lock after expiry: %v", err)
+	}
+}
+
+func TestLockRenewalKeepsHold(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	lock := client.NewDistributedLock("/lock", "holder", deimosclient.WithTTL(time.Second))
+	if err := lock.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	select {
+	case <-lock.Done():
+		t.Fatal("renewed hold ended")
+	case <-time.After(2500 * time.Millisecond):
+	}
+
+	if err := lock.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	select {
+	case <-lock.Done():
+	default:
+		t.Error("Done not closed after Unlock")
+	}
+}
+
+// flakyRenewal is a transport that fails the next renewal while armed.
+type flakyRenewal struct {
+	armed atomic.Bool
+}
+
+func (f *flakyRenewal) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPut && f.armed.CompareAndSwap(true, false) {
+		return nil, errors.New("connection reset")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestLockSurvivesFailedRenewal(t *testing.T) {
+	srv, _ := newTestClient(t)
+	ctx := testContext(t)
+
+	flaky := &flakyRenewal{}
+	client := srv.Client(deimosclient.WithTransport(flaky))
+	lock := client.NewDistributedLock("/lock", "holder", deimosclient.WithTTL(time.Second))
+	if err := lock.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	flaky.armed.Store(true)
+
+	select {
+	case <-lock.Done():
+		t.Fatal("hold ended after one failed renewal")
+	case <-time.After(2500 * time.Millisecond):
+	}
+	if flaky.armed.Load() {
+		t.Fatal("no renewal was attempted")
+	}
+	if !lock.IsHeld() {
+		t.Fatal("lock not held after one failed renewal")
+	}
+
+	if err := lock.Unlock(ctx); err != nil {
+		t.Errorf("unlock: %v", err)
+	}
+}
+
+func TestLockReentrant(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []deimosclient.LockOption
+		reentrant bool
+	}{
+		{name: "plain"},
+		{name: "reentrant", opts: []deimosclient.LockOption{deimosclient.WithReentrant()}, reentrant: true},
+		{name: "fair reentrant", opts: []deimosclient.LockOption{deimosclient.WithReentrant(), deimosclient.WithFairness()}, reentrant: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestClient(t)
+			ctx := testContext(t)
+
+			lock := client.NewDistributedLock("/lock", "holder", tt.opts...)
+			if err := lock.Lock(ctx); err != nil {
+				t.Fatalf("lock: %v", err)
+			}
+
+			err := lock.Lock(ctx)
+			if !tt.reentrant {
+				if !errors.Is(err, deimosclient.ErrLockAlreadyHeld) {
+					t.Fatalf("lock again = %v, want ErrLockAlreadyHeld", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lock again: %v", err)
+			}
+			if holds := lock.Info().Holds; holds != 2 {
+				t.Fatalf("holds = %d, want 2", holds)
+			}
+
+			// Only the outermost Unlock releases the lock.
+			if err := lock.Unlock(ctx); err != nil {
+				t.Fatalf("inner unlock: %v", err)
+			}
+			if status, err := client.LockStatus(ctx, "/lock"); err != nil || !status.Held {
+				t.Fatalf("status after inner unlock = %+v, %v, want held", status, err)
+			}
+
+			if err := lock.Unlock(ctx); err != nil {
+				t.Fatalf("outer unlock: %v", err)
+			}
+			if status, err := client.LockStatus(ctx, "/lock"); err != nil || status.Held {
+				t.Fatalf("status after outer unlock = %+v, %v, want free", status, err)
+			}
+			if err := lock.Unlock(ctx); !errors.Is(err, deimosclient.ErrLockNotHeld) {
+				t.Errorf("extra unlock = %v, want ErrLockNotHeld", err)
+			}
+		})
+	}
+}
+
+func TestLocksSharingValue(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	holder := client.NewDistributedLock("/lock", "host-1")
+	other := client.NewDistributedLock("/lock", "host-1")
+
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if err := other.TryLock(ctx); !errors.Is(err, deimosclient.ErrLockNotAcquired) {
+		t.Errorf("try lock = %v, want ErrLockNotAcquired", err)
+	}
+	if err := other.Unlock(ctx); !errors.Is(err, deimosclient.ErrLockNotHeld) {
+		t.Errorf("unlock by non-owner = %v, want ErrLockNotHeld", err)
+	}
+	if err := other.Renew(ctx); !errors.Is(err, deimosclient.ErrLockNotHeld) {
+		t.Errorf("renew by non-owner = %v, want ErrLockNotHeld", err)
+	}
+
+	status, err := client.LockStatus(ctx, "/lock")
+	if err != nil {
+		t.Fatalf("lock status: %v", err)
+	}
+	if info := holder.Info(); !status.Held || status.ModifiedIndex != info.LastIndex {
+		t.Fatalf("status = %+v, want held at index %d", status, info.LastIndex)
+	}
+	if err := holder.Renew(ctx); err != nil {
+		t.Errorf("renew by owner: %v", err)
+	}
+	if err := holder.Unlock(ctx); err != nil {
+		t.Errorf("unlock by owner: %v", err)
+	}
+}
+
+func TestLockMetadata(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	lock := client.NewDistributedLock("/lock", "worker", deimosclient.WithLockMetadata())
+	before := time.Now()
+	if err := lock.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	status, err := client.LockStatus(ctx, "/lock")
+	if err != nil {
+		t.Fatalf("lock status: %v", err)
+	}
+	metadata, err := deimosclient.ParseLockMetadata(status.Holder)
+	if err != nil {
+		t.Fatalf("parse metadata: %v", err)
+	}
+
+	host, _ := os.Hostname()
+	if metadata.Owner != "worker" || metadata.Host != host || metadata.PID != os.Getpid() {
+		t.Errorf("metadata = %+v, want owner worker on %s, pid %d", metadata, host, os.Getpid())
+	}
+	if metadata.AcquiredAt.Before(before.Truncate(time.Second)) || metadata.AcquiredAt.After(time.Now()) {
+		t.Errorf("acquired at %v, want about %v", metadata.AcquiredAt, before)
+	}
+
+	if err := lock.Unlock(ctx); err != nil {
+		t.Errorf("unlock: %v", err)
+	}
+}
+
+func TestFencingTokenAfterExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []deimosclient.LockOption
+	}{
+		{name: "plain"},
+		{name: "fair", opts: []deimosclient.LockOption{deimosclient.WithFairness()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestClient(t)
+			ctx := testContext(t)
+
+			first := client.NewDistributedLock("/lock", "first", append(tt.opts,
+				deimosclient.WithTTL(time.Second),
+				deimosclient.WithAutoRenewal(false),
+			)...)
+			second := client.NewDistributedLock("/lock", "second", tt.opts...)
+
+			if err := first.Lock(ctx); err != nil {
+				t.Fatalf("lock first: %v", err)
+			}
+			stale := first.FencingToken()
+			if err := client.ValidateFencingToken(ctx, "/lock", stale); err != nil {
+				t.Fatalf("validate holder's token: %v", err)
+			}
+
+			// The second holder gets the lock once the first one expired.
+			if err := second.Lock(ctx); err != nil {
+				t.Fatalf("lock second: %v", err)
+			}
+			requireDone(t, first.Done(), 3*time.Second)
+
+			token := second.FencingToken()
+			if token <= stale {
+				t.Errorf("token = %d, want above the stale token %d", token, stale)
+			}
+			if err := client.ValidateFencingToken(ctx, "/lock", stale); !errors.Is(err, deimosclient.ErrStaleFencingToken) {
+				t.Errorf("validate expired token = %v, want ErrStaleFencingToken", err)
+			}
+			if err := client.ValidateFencingToken(ctx, "/lock", token); err != nil {
+				t.Errorf("validate new token: %v", err)
+			}
+		})
+	}
+}
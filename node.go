@@ -1,10 +1,35 @@
 package deimosclient
 
+import "time"
+
 type Node struct {
-	Key           string  `json:"key"`
-	Value         string  `json:"value,omitempty"`
-	Dir           bool    `json:"dir,omitempty"`
-	Nodes         []*Node `json:"nodes,omitempty"`
-	ModifiedIndex uint64  `json:"modifiedIndex"`
-	CreatedIndex  uint64  `json:"createdIndex"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Dir   bool   `json:"dir,omitempty"`
+	// Expiration is when a key with a TTL expires, and TTL the number of
+	// seconds it had left when the server answered. Both are unset for
+	// keys without a TTL.
+	Expiration    *time.Time `json:"expiration,omitempty"`
+	TTL           int64      `json:"ttl,omitempty"`
+	Nodes         []*Node    `json:"nodes,omitempty"`
+	ModifiedIndex uint64     `json:"modifiedIndex"`
+	CreatedIndex  uint64     `json:"createdIndex"`
+}
+
+// HasTTL reports whether the node expires.
+func (n *Node) HasTTL() bool {
+	return n.Expiration != nil || n.TTL > 0
+}
+
+// Remaining returns the time left before the node expires, or zero if it
+// has expired or does not expire. It is computed from Expiration, falling
+// back to TTL when the server did not send one.
+func (n *Node) Remaining() time.Duration {
+	switch {
+	case n.Expiration != nil:
+		return max(time.Until(*n.Expiration), 0)
+	case n.TTL > 0:
+		return time.Duration(n.TTL) * time.Second
+	}
+	return 0
 }
@@ -1,6 +1,14 @@
 package deimosclient
 
-import "time"
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type ClientOption interface {
+	applyToClient(*ClientOptions)
+}
 
 type SetOption interface {
 	applyToSet(*SetOptions)
@@ -85,6 +93,21 @@ func (o *prevExistOption) applyToSet(opts *SetOptions) {
 	opts.prevExist = &o.prevExist
 }
 
+// WithRefresh makes Set only extend the TTL of an existing key, given with
+// WithTTL, without changing its value or notifying its watchers. The value
+// passed to Set is ignored. See Client.Refresh.
+func WithRefresh() SetOption {
+	return &refreshOption{refresh: true}
+}
+
+type refreshOption struct {
+	refresh bool
+}
+
+func (o *refreshOption) applyToSet(opts *SetOptions) {
+	opts.refresh = o.refresh
+}
+
 // recursive option
 func WithRecursive() GetDeleteWatchOption {
 	return &recursiveOption{recursive: true}
@@ -106,6 +129,20 @@ func (o *recursiveOption) applyToWatch(opts *WatchOptions) {
 	opts.recursive = o.recursive
 }
 
+// WithSorted returns the children of a directory sorted by key, which for
+// keys created with CreateInOrder is the order of creation.
+func WithSorted() GetOption {
+	return &sortedOption{sorted: true}
+}
+
+type sortedOption struct {
+	sorted bool
+}
+
+func (o *sortedOption) applyToGet(opts *GetOptions) {
+	opts.sorted = o.sorted
+}
+
 // waitIndex option
 func WithWaitIndex(waitIndex uint64) WatchOption {
 	return &waitIndexOption{waitIndex: waitIndex}
@@ -119,6 +156,67 @@ func (o *waitIndexOption) applyToWatch(opts *WatchOptions) {
 	opts.waitIndex = o.waitIndex
 }
 
+// WithReconnect makes a watch survive failed long-polls. It reconnects
+// with exponential backoff, switching to another endpoint, and resumes
+// from the last seen index so no event is lost or duplicated. Combine it
+// with WithWaitIndex to also cover failures before the first event.
+func WithReconnect() WatchOption {
+	return &reconnectOption{reconnect: true}
+}
+
+type reconnectOption struct {
+	reconnect bool
+}
+
+func (o *reconnectOption) applyToWatch(opts *WatchOptions) {
+	opts.reconnect = o.reconnect
+}
+
+// WithFairness makes the lock hand over ownership in FIFO order. The lock
+// key becomes a directory where every contender queues up with an in-order
+// key, and each contender only watches the one right before it.
+func WithFairness() LockOption {
+	return &fairnessOption{fair: true}
+}
+
+type fairnessOption struct {
+	fair bool
+}
+
+func (o *fairnessOption) applyToLock(opts *LockOptions) {
+	opts.fair = o.fair
+}
+
+// WithLockMetadata stores the owner of the lock as JSON in the lock node,
+// along with the host, pid and time of acquisition. See LockMetadata.
+func WithLockMetadata() LockOption {
+	return &lockMetadataOption{metadata: true}
+}
+
+type lockMetadataOption struct {
+	metadata bool
+}
+
+func (o *lockMetadataOption) applyToLock(opts *LockOptions) {
+	opts.metadata = o.metadata
+}
+
+// WithReentrant lets the holder of a lock acquire it again, e.g. in
+// nested WithLock calls. Holds are counted, and the lock is only released
+// by the Unlock matching the outermost acquisition. Without it, acquiring
+// a lock already held returns ErrLockAlreadyHeld.
+func WithReentrant() LockOption {
+	return &reentrantOption{reentrant: true}
+}
+
+type reentrantOption struct {
+	reentrant bool
+}
+
+func (o *reentrantOption) applyToLock(opts *LockOptions) {
+	opts.reentrant = o.reentrant
+}
+
 // WithRenewalPeriod sets the renewal period for auto-renewal
 func WithRenewalPeriod(period time.Duration) LockOption {
 	return &renewalPeriodOption{period: period}
@@ -144,3 +242,160 @@ type autoRenewalOption struct {
 func (o *autoRenewalOption) applyToLock(opts *LockOptions) {
 	opts.AutoRenewal = o.enabled
 }
+
+// WithHTTPClient sets the http.Client used to talk to the cluster. Its
+// Timeout applies to watches too, so it is usually better left at zero
+// in favor of WithRequestTimeout and WithWatchTimeout.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return &httpClientOption{httpClient: httpClient}
+}
+
+type httpClientOption struct {
+	httpClient *http.Client
+}
+
+func (o *httpClientOption) applyToClient(opts *ClientOptions) {
+	opts.httpClient = o.httpClient
+}
+
+// WithTransport sets the http.RoundTripper used to talk to the cluster.
+// WithDialTimeout and the TLS options have no effect when a transport is
+// given.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return &transportOption{transport: transport}
+}
+
+type transportOption struct {
+	transport http.RoundTripper
+}
+
+func (o *transportOption) applyToClient(opts *ClientOptions) {
+	opts.transport = o.transport
+}
+
+// WithRequestTimeout sets the timeout of every request except watches.
+// Zero disables it.
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return &requestTimeoutOption{timeout: timeout}
+}
+
+type requestTimeoutOption struct {
+	timeout time.Duration
+}
+
+func (o *requestTimeoutOption) applyToClient(opts *ClientOptions) {
+	opts.requestTimeout = o.timeout
+}
+
+// WithWatchTimeout sets how long a single watch long-poll may wait for an
+// event before it is re-issued. Zero, the default, waits indefinitely.
+// A watch started without WithWaitIndex first reads the current index of
+// the key, so that no event is lost between two polls.
+func WithWatchTimeout(timeout time.Duration) ClientOption {
+	return &watchTimeoutOption{timeout: timeout}
+}
+
+type watchTimeoutOption struct {
+	timeout time.Duration
+}
+
+func (o *watchTimeoutOption) applyToClient(opts *ClientOptions) {
+	opts.watchTimeout = o.timeout
+}
+
+// WithDialTimeout sets the timeout for establishing a connection
+func WithDialTimeout(timeout time.Duration) ClientOption {
+	return &dialTimeoutOption{timeout: timeout}
+}
+
+type dialTimeoutOption struct {
+	timeout time.Duration
+}
+
+func (o *dialTimeoutOption) applyToClient(opts *ClientOptions) {
+	opts.dialTimeout = o.timeout
+}
+
+// WithLogger sets the logger used for background failures
+func WithLogger(logger *slog.Logger) ClientOption {
+	return &loggerOption{logger: logger}
+}
+
+type loggerOption struct {
+	logger *slog.Logger
+}
+
+func (o *loggerOption) applyToClient(opts *ClientOptions) {
+	opts.logger = o.logger
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) ClientOption {
+	return &userAgentOption{userAgent: userAgent}
+}
+
+type userAgentOption struct {
+	userAgent string
+}
+
+func (o *userAgentOption) applyToClient(opts *ClientOptions) {
+	opts.userAgent = o.userAgent
+}
+
+// WithCACert sets the PEM encoded CA bundle used to verify the servers.
+// The file is reloaded when it changes on disk.
+func WithCACert(caFile string) ClientOption {
+	return &caCertOption{caFile: caFile}
+}
+
+type caCertOption struct {
+	caFile string
+}
+
+func (o *caCertOption) applyToClient(opts *ClientOptions) {
+	opts.tls.caFile = o.caFile
+}
+
+// WithClientCert sets the PEM encoded certificate and key presented to the
+// servers for mutual TLS. The files are reloaded when they change on disk.
+func WithClientCert(certFile, keyFile string) ClientOption {
+	return &clientCertOption{certFile: certFile, keyFile: keyFile}
+}
+
+type clientCertOption struct {
+	certFile string
+	keyFile  string
+}
+
+func (o *clientCertOption) applyToClient(opts *ClientOptions) {
+	opts.tls.certFile = o.certFile
+	opts.tls.keyFile = o.keyFile
+}
+
+// WithServerName overrides the server name used to verify the servers'
+// certificates, which defaults to the endpoint host.
+func WithServerName(serverName string) ClientOption {
+	return &serverNameOption{serverName: serverName}
+}
+
+type serverNameOption struct {
+	serverName string
+}
+
+func (o *serverNameOption) applyToClient(opts *ClientOptions) {
+	opts.tls.serverName = o.serverName
+}
+
+// WithMinTLSVersion sets the minimum TLS version, e.g. tls.VersionTLS13.
+// It defaults to TLS 1.2.
+func WithMinTLSVersion(version uint16) ClientOption {
+	return &minTLSVersionOption{version: version}
+}
+
+type minTLSVersionOption struct {
+	version uint16
+}
+
+func (o *minTLSVersionOption) applyToClient(opts *ClientOptions) {
+	opts.tls.minVersion = o.version
+}
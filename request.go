@@ -1,14 +1,27 @@
 package deimosclient
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 )
 
 func (c *Client) doRequest(req *http.Request) (*Response, error) {
-	resp, err := c.httpClient.Do(req)
+	req, cancel := c.withRequestTimeout(req)
+	defer cancel()
+
+	return c.exchange(req)
+}
+
+// exchange sends the request and decodes the deimos response, turning
+// error bodies into errors.
+func (c *Client) exchange(req *http.Request) (*Response, error) {
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("do http request failed: %w", err)
 	}
@@ -20,6 +33,10 @@ func (c *Client) doRequest(req *http.Request) (*Response, error) {
 	}
 
 	if resp.StatusCode >= 500 {
+		// Raft errors still come with an error code.
+		if apiErr := parseAPIError(resp.StatusCode, respBody); apiErr != nil {
+			return nil, apiErr
+		}
 		return nil, fmt.Errorf("deimos server error (HTTP %d): %s", resp.StatusCode, string(respBody))
 	}
 
@@ -34,8 +51,116 @@ func (c *Client) doRequest(req *http.Request) (*Response, error) {
 
 	// check errcode
 	if deimosResp.ErrorCode != 0 {
-		return nil, fmt.Errorf("deimos API err: [%d] %s", deimosResp.ErrorCode, deimosResp.Message)
+		return nil, parseAPIError(resp.StatusCode, respBody)
 	}
 
+	deimosResp.parseHeaders(resp.Header)
 	return &deimosResp, nil
 }
+
+// parseAPIError decodes an error body, returning nil if it does not carry
+// an error code.
+func parseAPIError(statusCode int, body []byte) *APIError {
+	var apiErr APIError
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.ErrorCode == 0 {
+		return nil
+	}
+	apiErr.StatusCode = statusCode
+	return &apiErr
+}
+
+// do sends the request to the endpoint it was built for. When the endpoint
+// cannot be reached, it is marked unhealthy in the cluster and the request
+// is retried against the next endpoint, as long as it is safe to do so.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	attempts := c.cluster.size()
+
+	if c.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	for attempt := 1; ; attempt++ {
+		endpoint := endpointOf(req.URL)
+
+		resp, err := c.httpClient.Do(req)
+		if err == nil {
+			c.cluster.success(endpoint)
+			// Followers answer writes with a 307 to the leader, which the
+			// http.Client follows. Remember where we ended up. Custom
+			// transports may leave the request unset.
+			if resp.Request == nil {
+				return resp, nil
+			}
+			if served := endpointOf(resp.Request.URL); !sameEndpoint(served, endpoint) {
+				c.cluster.updateLeader(served)
+			}
+			return resp, nil
+		}
+
+		// The caller gave up, there is nothing to fail over from.
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+
+		c.cluster.failure(endpoint)
+		if attempt >= attempts || !isRetryable(req, err) {
+			return nil, err
+		}
+
+		next, rebaseErr := rebaseRequest(req, c.cluster.pick())
+		if rebaseErr != nil {
+			return nil, err
+		}
+		req = next
+	}
+}
+
+// withRequestTimeout bounds the request by the client's request timeout.
+// The returned cancel func must be called once the body has been read.
+func (c *Client) withRequestTimeout(req *http.Request) (*http.Request, context.CancelFunc) {
+	if c.requestTimeout <= 0 {
+		return req, func() {}
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), c.requestTimeout)
+	return req.WithContext(ctx), cancel
+}
+
+// isRetryable reports whether a request that failed with err can be sent
+// again to another endpoint. Reads are always safe to retry; writes are only
+// retried when the connection could not be established, since the server
+// has not seen them yet.
+func isRetryable(req *http.Request, err error) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// rebaseRequest returns a copy of req pointed at the given endpoint.
+func rebaseRequest(req *http.Request, endpoint string) (*http.Request, error) {
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint %q failed: %w", endpoint, err)
+	}
+
+	next := req.Clone(req.Context())
+	next.URL.Scheme = base.Scheme
+	next.URL.Host = base.Host
+	next.Host = ""
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("rewind request body failed: %w", err)
+		}
+		next.Body = body
+	}
+
+	return next, nil
+}
+
+func endpointOf(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,167 @@
+package deimosclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+// bareTransport returns responses without their request, as custom
+// transports may.
+type bareTransport struct{}
+
+func (bareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = nil
+	return resp, nil
+}
+
+func TestResponseWithoutRequest(t *testing.T) {
+	srv, _ := newTestClient(t)
+	ctx := testContext(t)
+
+	client := srv.Client(deimosclient.WithTransport(bareTransport{}))
+	if _, err := client.Set(ctx, "/foo", "bar"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	resp, err := client.Get(ctx, "/foo")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if resp.Node.Value != "bar" {
+		t.Errorf("value = %q, want bar", resp.Node.Value)
+	}
+}
+
+// hostCounter is a transport that counts the requests sent to one host.
+type hostCounter struct {
+	host string
+	hits atomic.Int32
+}
+
+func (c *hostCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == c.host {
+		c.hits.Add(1)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// closedEndpoint returns the URL of a server that no longer listens.
+func closedEndpoint() string {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+// droppingEndpoint returns the URL of a server that reads each request and
+// drops the connection without answering. hits counts the requests.
+func droppingEndpoint(t *testing.T, hits *atomic.Int32) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		_ = conn.Close()
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func hostOf(t *testing.T, endpoint string) string {
+	t.Helper()
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		t.Fatalf("parse %q: %v", endpoint, err)
+	}
+	return u.Host
+}
+
+func TestFailoverFromClosedEndpoint(t *testing.T) {
+	srv, _ := newTestClient(t)
+	ctx := testContext(t)
+	dead := closedEndpoint()
+
+	// The first endpoint is picked at random, keep going until the closed
+	// one was tried.
+	counter := &hostCounter{host: hostOf(t, dead)}
+	for i := 0; i < 50 && counter.hits.Load() == 0; i++ {
+		client := deimosclient.NewClient([]string{dead, srv.URL}, deimosclient.WithTransport(counter))
+
+		// A write that never reached a server is safe to send again.
+		if _, err := client.Set(ctx, "/foo", "bar"); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		resp, err := client.Get(ctx, "/foo")
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if resp.Node.Value != "bar" {
+			t.Fatalf("value = %q, want bar", resp.Node.Value)
+		}
+	}
+	if counter.hits.Load() == 0 {
+		t.Fatal("closed endpoint never tried")
+	}
+}
+
+func TestFailoverAfterRequestSent(t *testing.T) {
+	srv, _ := newTestClient(t)
+	ctx := testContext(t)
+
+	var hits atomic.Int32
+	dropping := droppingEndpoint(t, &hits)
+
+	t.Run("get", func(t *testing.T) {
+		for i := 0; i < 50 && hits.Load() == 0; i++ {
+			client := deimosclient.NewClient([]string{dropping, srv.URL})
+			if _, err := client.Get(ctx, "/"); err != nil {
+				t.Fatalf("get: %v", err)
+			}
+		}
+		if hits.Load() == 0 {
+			t.Fatal("dropping endpoint never tried")
+		}
+	})
+
+	t.Run("post", func(t *testing.T) {
+		hits.Store(0)
+		for i := 0; i < 50 && hits.Load() == 0; i++ {
+			client := deimosclient.NewClient([]string{dropping, srv.URL})
+			index := srv.Index()
+
+			_, err := client.CreateInOrder(ctx, "/queue", "job")
+			if hits.Load() == 0 {
+				// The live server was picked first.
+				if err != nil {
+					t.Fatalf("create in order: %v", err)
+				}
+				continue
+			}
+
+			// The dropped request may have been applied, sending it again
+			// could enqueue the job twice.
+			if err == nil {
+				t.Fatal("create in order succeeded after its request was dropped")
+			}
+			if got := srv.Index(); got != index {
+				t.Errorf("index = %d, want %d: the write was retried", got, index)
+			}
+		}
+		if hits.Load() == 0 {
+			t.Fatal("dropping endpoint never tried")
+		}
+	})
+}
@@ -1,9 +1,56 @@
 package deimosclient
 
+import (
+	"net/http"
+	"strconv"
+)
+
+// ActionResync is the action of the synthetic event a watch emits when it
+// fell out of the server's event history and started over. Its Node is a
+// snapshot of the watched key, or nil if the key does not exist.
+const ActionResync = "resync"
+
+// Headers carrying the state of the cluster when it answered a request.
+// The server API derives from etcd v2, which sends the store index as
+// X-Etcd-Index and the raft state as X-Raft-Index and X-Raft-Term (see
+// writeKeyEvent in etcd's server/etcdserver/api/v2http/client.go). Deimos
+// names the store index after itself; the etcd name is still understood.
+const (
+	headerClusterIndex       = "X-Deimos-Index"
+	headerLegacyClusterIndex = "X-Etcd-Index"
+	headerRaftIndex          = "X-Raft-Index"
+	headerRaftTerm           = "X-Raft-Term"
+)
+
 type Response struct {
 	Action    string `json:"action"`
 	Node      *Node  `json:"node"`
 	PrevNode  *Node  `json:"prevNode,omitempty"`
 	ErrorCode int    `json:"errorCode,omitempty"`
 	Message   string `json:"message,omitempty"`
+
+	// ClusterIndex is the index of the store when the request was served.
+	// Watching from ClusterIndex+1 after a Get misses no change.
+	ClusterIndex uint64 `json:"-"`
+	// RaftIndex and RaftTerm are the raft state of the serving member.
+	RaftIndex uint64 `json:"-"`
+	RaftTerm  uint64 `json:"-"`
+}
+
+// parseHeaders reads the cluster state from the response headers. Missing
+// or malformed headers are left at zero.
+func (r *Response) parseHeaders(header http.Header) {
+	clusterIndex := header.Get(headerClusterIndex)
+	if clusterIndex == "" {
+		clusterIndex = header.Get(headerLegacyClusterIndex)
+	}
+	r.ClusterIndex, _ = strconv.ParseUint(clusterIndex, 10, 64)
+	r.RaftIndex, _ = strconv.ParseUint(header.Get(headerRaftIndex), 10, 64)
+	r.RaftTerm, _ = strconv.ParseUint(header.Get(headerRaftTerm), 10, 64)
+}
+
+// observedIndex returns the index up to which the response reflects the
+// store, so that watching from the next index misses no change.
+func observedIndex(resp *Response) uint64 {
+	return max(resp.ClusterIndex, latestModifiedIndex(resp.Node))
 }
@@ -0,0 +1,70 @@
+package deimosclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+func TestWatchFromClusterIndex(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	if _, err := client.Set(ctx, "/foo", "v1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	// A change to another key moves the cluster index past the key's own.
+	if _, err := client.Set(ctx, "/other", "x"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	resp, err := client.Get(ctx, "/foo")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if resp.ClusterIndex <= resp.Node.ModifiedIndex {
+		t.Fatalf("cluster index = %d, want past the key's index %d", resp.ClusterIndex, resp.Node.ModifiedIndex)
+	}
+	w := client.NewWatcher(ctx, "/foo", deimosclient.WithWaitIndex(resp.ClusterIndex+1))
+
+	if _, err := client.Set(ctx, "/foo", "v2"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if event := nextEvent(t, w); event.Action != "set" || event.Node.Value != "v2" {
+		t.Errorf("event = %s %+v, want set v2", event.Action, event.Node)
+	}
+}
+
+func TestClusterIndexHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{name: "deimos", header: "X-Deimos-Index"},
+		{name: "etcd", header: "X-Etcd-Index"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(tt.header, "42")
+				w.Header().Set("X-Raft-Index", "100")
+				w.Header().Set("X-Raft-Term", "3")
+				_, _ = w.Write([]byte(`{"action":"get","node":{"key":"/foo","value":"bar","modifiedIndex":7,"createdIndex":7}}`))
+			}))
+			t.Cleanup(srv.Close)
+
+			client := deimosclient.NewClient([]string{srv.URL})
+			resp, err := client.Get(testContext(t), "/foo")
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if resp.ClusterIndex != 42 || resp.RaftIndex != 100 || resp.RaftTerm != 3 {
+				t.Errorf("cluster index, raft index, raft term = %d, %d, %d, want 42, 100, 3",
+					resp.ClusterIndex, resp.RaftIndex, resp.RaftTerm)
+			}
+		})
+	}
+}
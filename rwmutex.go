@@ -0,0 +1,198 @@
+package deimosclient
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RWMutex is a distributed reader/writer lock. Any number of readers can
+// hold it at the same time, while a writer holds it exclusively.
+//
+// Under the lock key, writers queue up in FIFO order in the "writers"
+// directory, and readers register in-order entries in the "readers"
+// directory. Writers are preferred: as soon as a writer is queued, new
+// readers wait for the writer queue to drain, and the writer at the head
+// of the queue waits for the current readers to leave. Every hold is a
+// TTL'd node that is renewed in the background while held.
+type RWMutex struct {
+	client *Client
+	key    string
+	value  string
+	opts   []LockOption
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	readers []*DistributedLock
+	writer  *DistributedLock
+}
+
+// NewRWMutex creates a new distributed reader/writer lock. It accepts the
+// same options as NewDistributedLock.
+func (c *Client) NewRWMutex(key, value string, opts ...LockOption) *RWMutex {
+	lockOpts := newLockOptions(opts)
+
+	return &RWMutex{
+		client: c,
+		key:    key,
+		value:  value,
+		opts:   opts,
+		ttl:    lockOpts.ttl,
+	}
+}
+
+// RLock acquires a read hold, blocking while a writer holds the lock or
+// is waiting for it.
+func (rw *RWMutex) RLock(ctx context.Context) error {
+	reader := rw.client.NewDistributedLock(rw.readersKey(), rw.value, rw.opts...)
+	if reader.optsErr != nil {
+		return reader.optsErr
+	}
+
+	for {
+		// Writers take precedence, wait for the writer queue to drain.
+		if err := rw.client.waitEmpty(ctx, rw.writersKey()); err != nil {
+			return err
+		}
+
+		resp, err := rw.client.CreateInOrder(ctx, rw.readersKey(), reader.nodeValue(), WithCreateInOrderTTL(rw.ttl))
+		if err != nil {
+			return fmt.Errorf("failed to acquire read lock: %w", err)
+		}
+		entry := resp.Node
+
+		// A writer may have queued up between the check and our
+		// registration. It did not see us, so we have to step back.
+		empty, err := rw.client.isEmpty(ctx, rw.writersKey())
+		if err != nil || !empty {
+			if _, delErr := rw.client.Delete(context.WithoutCancel(ctx), entry.Key); delErr != nil && !IsKeyNotFound(delErr) {
+				rw.client.logger.Warn("Failed to withdraw read lock entry", "key", entry.Key, "err", delErr)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to acquire read lock: %w", err)
+			}
+			continue
+		}
+
+		reader.adopt(entry)
+
+		rw.mu.Lock()
+		rw.readers = append(rw.readers, reader)
+		rw.mu.Unlock()
+		return nil
+	}
+}
+
+// RUnlock releases the most recent read hold.
+func (rw *RWMutex) RUnlock(ctx context.Context) error {
+	rw.mu.Lock()
+	if len(rw.readers) == 0 {
+		rw.mu.Unlock()
+		return ErrLockNotHeld
+	}
+	reader := rw.readers[len(rw.readers)-1]
+	rw.readers = rw.readers[:len(rw.readers)-1]
+	rw.mu.Unlock()
+
+	return reader.Unlock(ctx)
+}
+
+// Lock acquires the write hold, blocking until every writer queued before
+// us and every current reader is gone. Like sync.RWMutex it is not
+// reentrant: goroutines sharing the RWMutex queue up as separate writers,
+// and calling Lock again while holding the write hold blocks.
+func (rw *RWMutex) Lock(ctx context.Context) error {
+	// Queueing up right away is what keeps new readers out.
+	writer := rw.client.NewDistributedLock(rw.writersKey(), rw.value, append(slices.Clip(rw.opts), WithFairness())...)
+	if err := writer.Lock(ctx); err != nil {
+		return err
+	}
+
+	if err := rw.client.waitEmpty(ctx, rw.readersKey()); err != nil {
+		if releaseErr := writer.Unlock(context.WithoutCancel(ctx)); releaseErr != nil {
+			rw.client.logger.Warn("Failed to release write lock", "key", rw.key, "err", releaseErr)
+		}
+		return err
+	}
+
+	rw.mu.Lock()
+	rw.writer = writer
+	rw.mu.Unlock()
+	return nil
+}
+
+// Unlock releases the write hold.
+func (rw *RWMutex) Unlock(ctx context.Context) error {
+	rw.mu.Lock()
+	writer := rw.writer
+	rw.writer = nil
+	rw.mu.Unlock()
+
+	if writer == nil {
+		return ErrLockNotHeld
+	}
+	return writer.Unlock(ctx)
+}
+
+func (rw *RWMutex) readersKey() string {
+	return rw.key + "/readers"
+}
+
+func (rw *RWMutex) writersKey() string {
+	return rw.key + "/writers"
+}
+
+// isEmpty reports whether the directory has no children. A missing
+// directory is empty.
+func (c *Client) isEmpty(ctx context.Context, dir string) (bool, error) {
+	resp, err := c.Get(ctx, dir)
+	if err != nil {
+		if IsKeyNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return len(resp.Node.Nodes) == 0, nil
+}
+
+// waitEmpty blocks until the directory has no children.
+func (c *Client) waitEmpty(ctx context.Context, dir string) error {
+	for {
+		resp, err := c.Get(ctx, dir)
+		if err != nil {
+			if IsKeyNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if len(resp.Node.Nodes) == 0 {
+			return nil
+		}
+
+		// Any change after the listing may have emptied the directory.
+		// Starting right after the index we listed at, none is missed.
+		if err := c.waitChange(ctx, dir, observedIndex(resp)+1); err != nil {
+			return err
+		}
+	}
+}
+
+// waitChange blocks until anything under dir changes at or after
+// waitIndex.
+func (c *Client) waitChange(ctx context.Context, dir string, waitIndex uint64) error {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := c.NewWatcher(watchCtx, dir, WithRecursive(), WithWaitIndex(waitIndex))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case _, ok := <-w.Events():
+		if !ok {
+			return w.Err()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package deimosclient_test
+
+import (
+	"errors"
+	"testing"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+func TestRWMutexReadersShare(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	first := client.NewRWMutex("/rw", "first")
+	second := client.NewRWMutex("/rw", "second")
+
+	if err := first.RLock(ctx); err != nil {
+		t.Fatalf("rlock first: %v", err)
+	}
+	if err := second.RLock(ctx); err != nil {
+		t.Fatalf("rlock second: %v", err)
+	}
+
+	if err := first.RUnlock(ctx); err != nil {
+		t.Fatalf("runlock first: %v", err)
+	}
+	if err := second.RUnlock(ctx); err != nil {
+		t.Fatalf("runlock second: %v", err)
+	}
+	if err := first.RUnlock(ctx); !errors.Is(err, deimosclient.ErrLockNotHeld) {
+		t.Errorf("extra runlock = %v, want ErrLockNotHeld", err)
+	}
+}
+
+func TestRWMutexWriterWaitsForReaders(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	reader := client.NewRWMutex("/rw", "reader")
+	writer := client.NewRWMutex("/rw", "writer")
+	lateReader := client.NewRWMutex("/rw", "late-reader")
+
+	if err := reader.RLock(ctx); err != nil {
+		t.Fatalf("rlock: %v", err)
+	}
+
+	writerResult := acquireAsync(ctx, writer.Lock)
+	requireWaiting(t, writerResult)
+
+	// A queued writer keeps new readers out.
+	lateResult := acquireAsync(ctx, lateReader.RLock)
+	requireWaiting(t, lateResult)
+
+	if err := reader.RUnlock(ctx); err != nil {
+		t.Fatalf("runlock: %v", err)
+	}
+	requireAcquired(t, writerResult)
+	requireWaiting(t, lateResult)
+
+	if err := writer.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	requireAcquired(t, lateResult)
+}
+
+func TestRWMutexSharedWriters(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	rw := client.NewRWMutex("/rw", "shared")
+
+	if err := rw.Lock(ctx); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	// Another goroutine sharing the RWMutex must wait its turn.
+	result := acquireAsync(ctx, rw.Lock)
+	requireWaiting(t, result)
+
+	if err := rw.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	requireAcquired(t, result)
+
+	if err := rw.Unlock(ctx); err != nil {
+		t.Fatalf("unlock second writer: %v", err)
+	}
+	if err := rw.Unlock(ctx); !errors.Is(err, deimosclient.ErrLockNotHeld) {
+		t.Errorf("extra unlock = %v, want ErrLockNotHeld", err)
+	}
+}
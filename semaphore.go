@@ -0,0 +1,226 @@
+package deimosclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSemaphoreFull          = errors.New("not enough semaphore slots available")
+	ErrSemaphoreNotHeld       = errors.New("semaphore is not held by this client")
+	ErrInvalidSemaphoreWeight = errors.New("invalid semaphore weight")
+)
+
+// Semaphore is a distributed counting semaphore limiting the total weight
+// of concurrent holders to a fixed size.
+//
+// Every acquisition creates an in-order entry under the semaphore key,
+// holding its weight as the value. Entries are served in FIFO order: an
+// entry holds the semaphore once the total weight of the entries up to and
+// including it fits in the size, so heavy acquisitions are not starved by
+// light ones. Entries have a TTL and, with auto-renewal, are renewed in
+// the background while waiting and while held. Without auto-renewal,
+// waiting entries are still renewed so that they keep their place in line,
+// but held entries expire after the TTL.
+type Semaphore struct {
+	client *Client
+	key    string
+	size   int64
+	opts   []LockOption
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	holds []*DistributedLock
+}
+
+// NewSemaphore creates a new distributed semaphore of the given size. It
+// accepts the same options as NewDistributedLock. All users of a
+// semaphore must agree on its size.
+func (c *Client) NewSemaphore(key string, size int64, opts ...LockOption) *Semaphore {
+	lockOpts := newLockOptions(opts)
+
+	return &Semaphore{
+		client: c,
+		key:    key,
+		size:   size,
+		opts:   opts,
+		ttl:    lockOpts.ttl,
+	}
+}
+
+// Acquire acquires the semaphore with the given weight, blocking until
+// enough slots are available or the context is cancelled.
+func (s *Semaphore) Acquire(ctx context.Context, weight int64) error {
+	if err := s.checkWeight(weight); err != nil {
+		return err
+	}
+
+	hold, err := s.enqueue(ctx, weight)
+	if err != nil {
+		return err
+	}
+	stopRenewal := s.renewWhileWaiting(hold)
+
+	for {
+		fits, found, index, err := s.fits(ctx, hold.holdKey())
+		if err != nil {
+			s.withdraw(ctx, hold)
+			return fmt.Errorf("failed to acquire semaphore: %w", err)
+		}
+
+		if !found {
+			// Our entry expired while waiting, get back in line.
+			s.withdraw(ctx, hold)
+			if hold, err = s.enqueue(ctx, weight); err != nil {
+				return err
+			}
+			stopRenewal = s.renewWhileWaiting(hold)
+			continue
+		}
+
+		if fits {
+			stopRenewal()
+
+			s.mu.Lock()
+			s.holds = append(s.holds, hold)
+			s.mu.Unlock()
+			return nil
+		}
+
+		if err := s.client.waitChange(ctx, s.key, index+1); err != nil {
+			s.withdraw(ctx, hold)
+			return err
+		}
+	}
+}
+
+// TryAcquire acquires the semaphore with the given weight without
+// blocking. It returns ErrSemaphoreFull if not enough slots are available.
+func (s *Semaphore) TryAcquire(ctx context.Context, weight int64) error {
+	if err := s.checkWeight(weight); err != nil {
+		return err
+	}
+
+	hold, err := s.enqueue(ctx, weight)
+	if err != nil {
+		return err
+	}
+
+	fits, found, _, err := s.fits(ctx, hold.holdKey())
+	if err != nil || !fits || !found {
+		s.withdraw(ctx, hold)
+	}
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to acquire semaphore: %w", err)
+	case !found:
+		return fmt.Errorf("%w: semaphore entry expired", ErrSemaphoreFull)
+	case !fits:
+		return ErrSemaphoreFull
+	}
+
+	s.mu.Lock()
+	s.holds = append(s.holds, hold)
+	s.mu.Unlock()
+	return nil
+}
+
+// Release releases the most recent acquisition of the semaphore.
+func (s *Semaphore) Release(ctx context.Context) error {
+	s.mu.Lock()
+	if len(s.holds) == 0 {
+		s.mu.Unlock()
+		return ErrSemaphoreNotHeld
+	}
+	hold := s.holds[len(s.holds)-1]
+	s.holds = s.holds[:len(s.holds)-1]
+	s.mu.Unlock()
+
+	if err := hold.Unlock(ctx); err != nil {
+		return fmt.Errorf("failed to release semaphore: %w", err)
+	}
+	return nil
+}
+
+// Size returns the size of the semaphore.
+func (s *Semaphore) Size() int64 {
+	return s.size
+}
+
+func (s *Semaphore) checkWeight(weight int64) error {
+	if weight <= 0 || weight > s.size {
+		return fmt.Errorf("%w: %d not in [1, %d]", ErrInvalidSemaphoreWeight, weight, s.size)
+	}
+	return nil
+}
+
+// enqueue creates our entry at the end of the queue and keeps it alive.
+func (s *Semaphore) enqueue(ctx context.Context, weight int64) (*DistributedLock, error) {
+	value := strconv.FormatInt(weight, 10)
+
+	l := s.client.NewDistributedLock(s.key, value, s.opts...)
+	if l.optsErr != nil {
+		return nil, l.optsErr
+	}
+
+	resp, err := s.client.CreateInOrder(ctx, s.key, value, WithCreateInOrderTTL(s.ttl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue semaphore entry: %w", err)
+	}
+
+	l.adopt(resp.Node)
+	return l, nil
+}
+
+// renewWhileWaiting keeps our entry alive while we wait in line, which the
+// hold only does by itself with auto-renewal. The returned func stops the
+// renewal once the entry is served.
+func (s *Semaphore) renewWhileWaiting(hold *DistributedLock) func() {
+	if hold.autoRenewal || s.ttl <= 0 {
+		return func() {}
+	}
+
+	hold.StartAutoRenewal(context.Background(), s.ttl/3)
+	return hold.StopAutoRenewal
+}
+
+// withdraw removes our entry from the queue. It runs even if ctx is
+// cancelled, so that an abandoned entry does not take up slots until it
+// expires.
+func (s *Semaphore) withdraw(ctx context.Context, hold *DistributedLock) {
+	key := hold.holdKey()
+	if err := hold.Unlock(context.WithoutCancel(ctx)); err != nil && !errors.Is(err, ErrLockNotHeld) && !IsKeyNotFound(err) {
+		s.client.logger.Warn("Failed to withdraw semaphore entry", "key", key, "err", err)
+	}
+}
+
+// fits reports whether the entries up to and including ours fit in the
+// semaphore. found is false if our entry is no longer in the queue. index
+// is the latest index seen in the listing.
+func (s *Semaphore) fits(ctx context.Context, entryKey string) (fits, found bool, index uint64, err error) {
+	resp, err := s.client.Get(ctx, s.key, WithSorted())
+	if err != nil {
+		if IsKeyNotFound(err) {
+			return false, false, 0, nil
+		}
+		return false, false, 0, err
+	}
+
+	var total int64
+	for _, node := range resp.Node.Nodes {
+		weight, err := strconv.ParseInt(node.Value, 10, 64)
+		if err != nil {
+			return false, false, 0, fmt.Errorf("invalid semaphore entry %s: %w", node.Key, err)
+		}
+		total += weight
+
+		if node.Key == entryKey {
+			return total <= s.size, true, observedIndex(resp), nil
+		}
+	}
+	return false, false, 0, nil
+}
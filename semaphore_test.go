@@ -0,0 +1,125 @@
+package deimosclient_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+func TestSemaphoreWeights(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	sem := client.NewSemaphore("/sem", 3)
+
+	if err := sem.Acquire(ctx, 2); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if err := sem.TryAcquire(ctx, 2); !errors.Is(err, deimosclient.ErrSemaphoreFull) {
+		t.Fatalf("try acquire over size = %v, want ErrSemaphoreFull", err)
+	}
+	if err := sem.TryAcquire(ctx, 1); err != nil {
+		t.Fatalf("try acquire: %v", err)
+	}
+
+	if err := sem.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := sem.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := sem.Release(ctx); !errors.Is(err, deimosclient.ErrSemaphoreNotHeld) {
+		t.Errorf("extra release = %v, want ErrSemaphoreNotHeld", err)
+	}
+}
+
+func TestSemaphoreInvalidWeight(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	sem := client.NewSemaphore("/sem", 2)
+
+	for _, weight := range []int64{0, -1, 3} {
+		if err := sem.Acquire(ctx, weight); !errors.Is(err, deimosclient.ErrInvalidSemaphoreWeight) {
+			t.Errorf("acquire %d = %v, want ErrInvalidSemaphoreWeight", weight, err)
+		}
+	}
+}
+
+func TestSemaphoreFIFO(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	holder := client.NewSemaphore("/sem", 3)
+	heavy := client.NewSemaphore("/sem", 3)
+	light := client.NewSemaphore("/sem", 3)
+
+	if err := holder.Acquire(ctx, 2); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	heavyResult := acquireAsync(ctx, func(ctx context.Context) error { return heavy.Acquire(ctx, 2) })
+	waitEntries(t, client, "/sem", 2)
+
+	// The light acquisition would fit, but it is queued behind the heavy one.
+	lightResult := acquireAsync(ctx, func(ctx context.Context) error { return light.Acquire(ctx, 1) })
+	requireWaiting(t, lightResult)
+
+	if err := holder.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	requireAcquired(t, heavyResult)
+	requireAcquired(t, lightResult)
+}
+
+func TestSemaphoreWaiterKeepsPlaceWithoutAutoRenewal(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	holder := client.NewSemaphore("/sem", 1, deimosclient.WithTTL(time.Second))
+	waiter := client.NewSemaphore("/sem", 1, deimosclient.WithTTL(time.Second), deimosclient.WithAutoRenewal(false))
+
+	if err := holder.Acquire(ctx, 1); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	result := acquireAsync(ctx, func(ctx context.Context) error { return waiter.Acquire(ctx, 1) })
+	entries := waitEntries(t, client, "/sem", 2)
+
+	// Outlive the TTL of the waiting entry.
+	time.Sleep(2500 * time.Millisecond)
+	requireWaiting(t, result)
+
+	resp, err := client.Get(ctx, "/sem", deimosclient.WithSorted())
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(resp.Node.Nodes) != 2 || resp.Node.Nodes[1].Key != entries[1].Key {
+		t.Fatalf("queue = %v, want the waiting entry %s still in place", resp.Node.Nodes, entries[1].Key)
+	}
+
+	if err := holder.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	requireAcquired(t, result)
+}
+
+// waitEntries waits until the directory holds the given number of
+// entries, and returns them in order.
+func waitEntries(t *testing.T, client *deimosclient.Client, dir string, n int) []*deimosclient.Node {
+	t.Helper()
+
+	var entries []*deimosclient.Node
+	waitFor(t, "queue entries", func() bool {
+		resp, err := client.Get(context.Background(), dir, deimosclient.WithSorted())
+		if err != nil {
+			return false
+		}
+		entries = resp.Node.Nodes
+		return len(entries) == n
+	})
+	return entries
+}
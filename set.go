@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,6 +15,7 @@ type SetOptions struct {
 	ttl       time.Duration
 	dir       bool
 	prevExist *bool
+	refresh   bool
 }
 
 func newSetOptions(options []SetOption) *SetOptions {
@@ -28,12 +31,16 @@ func newSetOptions(options []SetOption) *SetOptions {
 func (c *Client) Set(ctx context.Context, key, value string, opts ...SetOption) (*Response, error) {
 	setOpts := newSetOptions(opts)
 
-	URL := c.buildURL(key)
+	URL := c.buildLeaderURL(key)
 	query := url.Values{}
 
-	if setOpts.dir {
+	switch {
+	case setOpts.dir:
 		query.Set("dir", "true")
-	} else {
+	case setOpts.refresh:
+		// A refresh keeps the current value.
+		query.Set("refresh", "true")
+	default:
 		query.Set("value", value)
 	}
 
@@ -42,11 +49,7 @@ func (c *Client) Set(ctx context.Context, key, value string, opts ...SetOption)
 	}
 
 	if setOpts.prevExist != nil {
-		if *setOpts.prevExist {
-			query.Set("prevExists", "true")
-		} else {
-			query.Set("prevExists", "false")
-		}
+		query.Set("prevExist", strconv.FormatBool(*setOpts.prevExist))
 	}
 
 	body := strings.NewReader(query.Encode())
@@ -58,3 +61,38 @@ func (c *Client) Set(ctx context.Context, key, value string, opts ...SetOption)
 
 	return c.doRequest(req)
 }
+
+// Refresh extends the TTL of an existing key without changing its value.
+// Unlike setting the key again, it does not notify the watchers of the
+// key. It fails with an error satisfying IsKeyNotFound if the key does
+// not exist.
+func (c *Client) Refresh(ctx context.Context, key string, ttl time.Duration) (*Response, error) {
+	return c.Set(ctx, key, "", WithTTL(ttl), WithRefresh(), WithPrevExist(true))
+}
+
+// Create creates a key that must not exist yet. It fails with an error
+// satisfying IsNodeExist if the key already exists.
+func (c *Client) Create(ctx context.Context, key, value string, opts ...SetOption) (*Response, error) {
+	return c.Set(ctx, key, value, append(slices.Clip(opts), WithPrevExist(false))...)
+}
+
+// CreateDir creates a directory that must not exist yet. It fails with an
+// error satisfying IsNodeExist if the key already exists.
+func (c *Client) CreateDir(ctx context.Context, key string, opts ...SetOption) (*Response, error) {
+	return c.Set(ctx, key, "", append(slices.Clip(opts), WithDir(), WithPrevExist(false))...)
+}
+
+// Update changes the value of a key that must exist. It fails with an
+// error satisfying IsKeyNotFound if the key does not exist, or IsNotFile
+// if it is a directory.
+func (c *Client) Update(ctx context.Context, key, value string, opts ...SetOption) (*Response, error) {
+	return c.Set(ctx, key, value, append(slices.Clip(opts), WithPrevExist(true))...)
+}
+
+// UpdateDir changes the TTL of a directory that must exist, given with
+// WithTTL; without it, the directory no longer expires. It fails with an
+// error satisfying IsKeyNotFound if the directory does not exist, or
+// IsNotDir if the key is a file.
+func (c *Client) UpdateDir(ctx context.Context, key string, opts ...SetOption) (*Response, error) {
+	return c.Set(ctx, key, "", append(slices.Clip(opts), WithDir(), WithPrevExist(true))...)
+}
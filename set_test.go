@@ -0,0 +1,148 @@
+package deimosclient_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+func TestCreate(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	if _, err := client.Create(ctx, "/foo", "v1"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := client.Create(ctx, "/foo", "v2"); !deimosclient.IsNodeExist(err) {
+		t.Errorf("create existing key = %v, want IsNodeExist", err)
+	}
+
+	if _, err := client.CreateDir(ctx, "/dir"); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if _, err := client.CreateDir(ctx, "/dir"); !deimosclient.IsNodeExist(err) {
+		t.Errorf("create existing dir = %v, want IsNodeExist", err)
+	}
+
+	resp, err := client.Get(ctx, "/foo")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if resp.Node.Value != "v1" {
+		t.Errorf("value = %q, want v1", resp.Node.Value)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	if _, err := client.Update(ctx, "/foo", "v1"); !deimosclient.IsKeyNotFound(err) {
+		t.Errorf("update missing key = %v, want IsKeyNotFound", err)
+	}
+	if _, err := client.UpdateDir(ctx, "/dir"); !deimosclient.IsKeyNotFound(err) {
+		t.Errorf("update missing dir = %v, want IsKeyNotFound", err)
+	}
+
+	if _, err := client.Set(ctx, "/foo", "v1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, err := client.Update(ctx, "/foo", "v2"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	resp, err := client.Get(ctx, "/foo")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if resp.Node.Value != "v2" {
+		t.Errorf("value = %q, want v2", resp.Node.Value)
+	}
+
+	if _, err := client.CreateDir(ctx, "/dir"); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if _, err := client.UpdateDir(ctx, "/dir"); err != nil {
+		t.Errorf("update dir: %v", err)
+	}
+}
+
+func TestPrevExistRejectsUnknownValues(t *testing.T) {
+	srv, _ := newTestClient(t)
+	ctx := testContext(t)
+
+	tests := []struct {
+		prevExist string
+		status    int
+	}{
+		{prevExist: "true", status: http.StatusNotFound},
+		{prevExist: "false", status: http.StatusCreated},
+		{prevExist: "yes", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		form := url.Values{"value": {"bar"}, "prevExist": {tt.prevExist}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, srv.URL+"/keys/foo", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("prevExist=%s: status = %d, want %d", tt.prevExist, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	set, err := client.Set(ctx, "/foo", "bar", deimosclient.WithTTL(2*time.Second))
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	w := client.NewWatcher(ctx, "/foo", deimosclient.WithWaitIndex(set.Node.ModifiedIndex+1))
+
+	resp, err := client.Refresh(ctx, "/foo", time.Minute)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if resp.Node.TTL <= 2 || !resp.Node.Expiration.After(*set.Node.Expiration) {
+		t.Errorf("refreshed ttl = %d, expiration = %v, want past %v", resp.Node.TTL, resp.Node.Expiration, set.Node.Expiration)
+	}
+
+	get, err := client.Get(ctx, "/foo")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if get.Node.Value != "bar" {
+		t.Errorf("value = %q, want bar", get.Node.Value)
+	}
+
+	// Watchers see the next change, not the refresh.
+	if _, err := client.Set(ctx, "/foo", "baz"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if event := nextEvent(t, w); event.Action != "set" || event.Node.Value != "baz" {
+		t.Errorf("event = %s %+v, want set baz", event.Action, event.Node)
+	}
+}
+
+func TestRefreshMissingKey(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	if _, err := client.Refresh(ctx, "/foo", time.Minute); !deimosclient.IsKeyNotFound(err) {
+		t.Errorf("refresh missing key = %v, want IsKeyNotFound", err)
+	}
+}
@@ -0,0 +1,130 @@
+package deimosclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoMachines is returned by SyncCluster when the server reports an
+	// empty membership.
+	ErrNoMachines = errors.New("deimos cluster reported no machines")
+	// ErrInvalidSyncInterval is returned by StartAutoSync when the interval
+	// is not positive.
+	ErrInvalidSyncInterval = errors.New("sync interval must be positive")
+)
+
+// SyncCluster fetches the live membership from the /machines endpoint and
+// replaces the endpoint list of the client with it.
+// Note that the server reports the advertised client URLs of its members,
+// so they must be reachable from where the client runs.
+//
+// /machines does not tell which member leads, so syncing does not find the
+// leader. Writes go to the leader once a follower redirected one of them
+// there; until then, as after startup or a failover, they may pay for a
+// hop through a follower.
+func (c *Client) SyncCluster(ctx context.Context) error {
+	machines, err := c.machines(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.cluster.update(machines)
+	return nil
+}
+
+// StartAutoSync starts syncing the cluster membership in the background
+// every interval, until the context is cancelled. It returns
+// ErrInvalidSyncInterval without starting anything if the interval is not
+// positive.
+func (c *Client) StartAutoSync(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidSyncInterval, interval)
+	}
+
+	go c.autoSyncLoop(ctx, interval)
+	return nil
+}
+
+// autoSyncLoop runs the periodic membership sync.
+func (c *Client) autoSyncLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.SyncCluster(ctx); err != nil && ctx.Err() == nil {
+				c.logger.Warn("Failed to sync cluster", "err", err)
+			}
+		}
+	}
+}
+
+// machines returns the client URLs of the cluster members.
+func (c *Client) machines(ctx context.Context) ([]string, error) {
+	URL := c.cluster.pick() + "/machines"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create deimos request failed: %w", err)
+	}
+
+	req, cancel := c.withRequestTimeout(req)
+	defer cancel()
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do http request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("deimos machines error (HTTP %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	machines, err := parseMachines(respBody)
+	if err != nil {
+		return nil, err
+	}
+	if len(machines) == 0 {
+		return nil, ErrNoMachines
+	}
+	return machines, nil
+}
+
+// parseMachines accepts both a comma separated list and a JSON array of
+// client URLs.
+func parseMachines(body []byte) ([]string, error) {
+	text := strings.TrimSpace(string(body))
+
+	var raw []string
+	if strings.HasPrefix(text, "[") {
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, fmt.Errorf("umarshal json error: %w", err)
+		}
+	} else {
+		raw = strings.Split(text, ",")
+	}
+
+	machines := make([]string, 0, len(raw))
+	for _, machine := range raw {
+		if machine = strings.TrimSpace(machine); machine != "" {
+			machines = append(machines, machine)
+		}
+	}
+	return machines, nil
+}
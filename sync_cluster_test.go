@@ -0,0 +1,35 @@
+package deimosclient_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+func TestSyncCluster(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	if err := client.SyncCluster(ctx); err != nil {
+		t.Fatalf("sync cluster: %v", err)
+	}
+	if _, err := client.Set(ctx, "/foo", "bar"); err != nil {
+		t.Errorf("set after sync: %v", err)
+	}
+}
+
+func TestStartAutoSyncInvalidInterval(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := testContext(t)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if err := client.StartAutoSync(ctx, interval); !errors.Is(err, deimosclient.ErrInvalidSyncInterval) {
+			t.Errorf("StartAutoSync(%v) = %v, want ErrInvalidSyncInterval", interval, err)
+		}
+	}
+	if err := client.StartAutoSync(ctx, time.Minute); err != nil {
+		t.Errorf("StartAutoSync: %v", err)
+	}
+}
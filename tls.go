@@ -0,0 +1,156 @@
+package deimosclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoCACertificates is returned when a CA bundle contains no PEM
+// encoded certificates.
+var ErrNoCACertificates = errors.New("no CA certificates found in bundle")
+
+// tlsOptions contains the TLS settings of a client
+type tlsOptions struct {
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+	minVersion uint16
+}
+
+func (o *tlsOptions) enabled() bool {
+	return o.caFile != "" || o.certFile != "" || o.serverName != "" || o.minVersion != 0
+}
+
+// tlsReloader keeps the CA bundle and client certificate loaded from disk
+// and reloads them when the files change, so that rotated certificates are
+// picked up by new connections without restarting the client.
+type tlsReloader struct {
+	opts tlsOptions
+
+	mu      sync.Mutex
+	caMod   time.Time
+	certMod time.Time
+	keyMod  time.Time
+	rootCAs *x509.CertPool
+	cert    *tls.Certificate
+}
+
+func newTLSReloader(opts tlsOptions) *tlsReloader {
+	return &tlsReloader{opts: opts}
+}
+
+// dialContext establishes a TLS connection with the current certificates.
+func (r *tlsReloader) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		config, err := r.config(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: config}
+		return tlsDialer.DialContext(ctx, network, addr)
+	}
+}
+
+// config returns the tls.Config for a connection to addr.
+func (r *tlsReloader) config(addr string) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	serverName := r.opts.serverName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		serverName = host
+	}
+
+	minVersion := r.opts.minVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+
+	config := &tls.Config{
+		RootCAs:    r.rootCAs,
+		ServerName: serverName,
+		MinVersion: minVersion,
+	}
+	if r.cert != nil {
+		config.Certificates = []tls.Certificate{*r.cert}
+	}
+	return config, nil
+}
+
+// reload reads the files again if they were modified since the last load.
+func (r *tlsReloader) reload() error {
+	if r.opts.caFile != "" {
+		modTime, err := modTimeOf(r.opts.caFile)
+		if err != nil {
+			return err
+		}
+		if r.rootCAs == nil || !modTime.Equal(r.caMod) {
+			pool, err := loadCertPool(r.opts.caFile)
+			if err != nil {
+				return err
+			}
+			r.rootCAs = pool
+			r.caMod = modTime
+		}
+	}
+
+	if r.opts.certFile != "" {
+		certMod, err := modTimeOf(r.opts.certFile)
+		if err != nil {
+			return err
+		}
+		keyMod, err := modTimeOf(r.opts.keyFile)
+		if err != nil {
+			return err
+		}
+		if r.cert == nil || !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod) {
+			cert, err := tls.LoadX509KeyPair(r.opts.certFile, r.opts.keyFile)
+			if err != nil {
+				return fmt.Errorf("load client certificate failed: %w", err)
+			}
+			r.cert = &cert
+			r.certMod = certMod
+			r.keyMod = keyMod
+		}
+	}
+
+	return nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle failed: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w: %s", ErrNoCACertificates, caFile)
+	}
+	return pool, nil
+}
+
+func modTimeOf(file string) (time.Time, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat %s failed: %w", file, err)
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,211 @@
+package deimosclient_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+)
+
+// testCert is a certificate and its key, signed by a CA or self-signed.
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, name string, ca *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("generate serial: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	parent, signer := template, key
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parent, signer = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return &testCert{cert: cert, der: der, key: key}
+}
+
+func (c *testCert) tlsCertificate() *tls.Certificate {
+	return &tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// writeCert writes the certificate, and its key if keyFile is set. The
+// files are dated in the future so that each write changes their
+// modification time.
+func (c *testCert) write(t *testing.T, certFile, keyFile string, at time.Time) {
+	t.Helper()
+
+	writePEM(t, certFile, "CERTIFICATE", c.der, at)
+	if keyFile != "" {
+		der, err := x509.MarshalECPrivateKey(c.key)
+		if err != nil {
+			t.Fatalf("marshal key: %v", err)
+		}
+		writePEM(t, keyFile, "EC PRIVATE KEY", der, at)
+	}
+}
+
+func writePEM(t *testing.T, file, blockType string, der []byte, at time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", file, err)
+	}
+	if err := os.Chtimes(file, at, at); err != nil {
+		t.Fatalf("chtimes %s: %v", file, err)
+	}
+}
+
+// tlsServer is a server that requires client certificates signed by
+// clientCA, and records the name of the last client.
+type tlsServer struct {
+	*httptest.Server
+	cert   atomic.Pointer[tls.Certificate]
+	client atomic.Value
+}
+
+func newTLSServer(t *testing.T, cert, clientCA *testCert) *tlsServer {
+	t.Helper()
+
+	s := &tlsServer{}
+	s.cert.Store(cert.tlsCertificate())
+
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.client.Store(r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"action":"get","node":{"key":"/foo","value":"bar","modifiedIndex":1,"createdIndex":1}}`))
+	}))
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+	s.TLS = &tls.Config{
+		// Clients connect by IP and send no server name, for which the
+		// static certificates would win over GetCertificate.
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				Certificates: []tls.Certificate{*s.cert.Load()},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    clientCAs,
+			}, nil
+		},
+	}
+	s.StartTLS()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestTLSClientCertificates(t *testing.T) {
+	ctx := testContext(t)
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+
+	ca := newTestCert(t, "ca", nil)
+	srv := newTLSServer(t, newTestCert(t, "server", ca), ca)
+
+	now := time.Now()
+	ca.write(t, caFile, "", now)
+	newTestCert(t, "client-1", ca).write(t, certFile, keyFile, now)
+
+	client := deimosclient.NewClient([]string{srv.URL},
+		deimosclient.WithCACert(caFile),
+		deimosclient.WithClientCert(certFile, keyFile),
+	)
+	get := func() error {
+		_, err := client.Get(ctx, "/foo")
+		return err
+	}
+
+	if err := get(); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if name := srv.client.Load(); name != "client-1" {
+		t.Fatalf("client = %v, want client-1", name)
+	}
+
+	// A rotated client certificate is used by new connections.
+	newTestCert(t, "client-2", ca).write(t, certFile, keyFile, now.Add(time.Minute))
+	srv.CloseClientConnections()
+	if err := get(); err != nil {
+		t.Fatalf("get after client certificate rotation: %v", err)
+	}
+	if name := srv.client.Load(); name != "client-2" {
+		t.Errorf("client = %v, want client-2", name)
+	}
+
+	// The server moves to a certificate from a new CA, which is only
+	// trusted once the CA bundle is replaced.
+	newCA := newTestCert(t, "new-ca", nil)
+	srv.cert.Store(newTestCert(t, "server", newCA).tlsCertificate())
+	srv.CloseClientConnections()
+	if err := get(); err == nil {
+		t.Fatal("get succeeded against a server of an unknown CA")
+	}
+
+	newCA.write(t, caFile, "", now.Add(2*time.Minute))
+	if err := get(); err != nil {
+		t.Fatalf("get after CA rotation: %v", err)
+	}
+}
+
+func TestTLSEmptyCABundle(t *testing.T) {
+	ctx := testContext(t)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("no certificates here\n"), 0o600); err != nil {
+		t.Fatalf("write CA bundle: %v", err)
+	}
+
+	ca := newTestCert(t, "ca", nil)
+	srv := newTLSServer(t, newTestCert(t, "server", ca), ca)
+
+	client := deimosclient.NewClient([]string{srv.URL}, deimosclient.WithCACert(caFile))
+	if _, err := client.Get(ctx, "/foo"); !errors.Is(err, deimosclient.ErrNoCACertificates) {
+		t.Errorf("get = %v, want ErrNoCACertificates", err)
+	}
+}
@@ -4,5 +4,18 @@ import "fmt"
 
 func (c *Client) buildURL(key string) string {
 	endpoint := c.cluster.pick()
+	return keysURL(endpoint, key)
+}
+
+// buildLeaderURL is like buildURL, but targets the leader so that writes
+// do not pay for an extra hop through a follower. The leader is only known
+// from the redirects of earlier writes, so the first write after startup or
+// a failover may still go through a follower.
+func (c *Client) buildLeaderURL(key string) string {
+	endpoint := c.cluster.pickLeader()
+	return keysURL(endpoint, key)
+}
+
+func keysURL(endpoint, key string) string {
 	return fmt.Sprintf("%s/keys%s", endpoint, key)
 }
@@ -2,17 +2,30 @@ package deimosclient
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
+)
+
+// errPollTimeout is returned by poll when a long-poll reached the watch
+// timeout without an event.
+var errPollTimeout = errors.New("watch poll timed out")
+
+const (
+	// minReconnectBackoff is the delay before the first reconnection attempt.
+	minReconnectBackoff = 100 * time.Millisecond
+	// maxReconnectBackoff caps the delay between reconnection attempts.
+	maxReconnectBackoff = 10 * time.Second
 )
 
 // WatchOptions contains all optional parameters for a Watch operation.
 type WatchOptions struct {
 	recursive bool   // Whether to recursively watch a directory.
 	waitIndex uint64 // The index to start waiting from.
+	reconnect bool   // Whether to reconnect when the long-poll fails.
 }
 
 func newWatchOptions(options []WatchOption) *WatchOptions {
@@ -25,96 +38,257 @@ func newWatchOptions(options []WatchOption) *WatchOptions {
 	return &watchOpts
 }
 
+// Watcher delivers the events of a watch on a key.
+// If the watch falls out of the server's event history, an ActionResync
+// event with a snapshot of the key is delivered and the watch continues
+// from there.
+// Events is closed when the watch ends, after which Err reports why:
+// the context error when the caller stopped it, an *APIError when the
+// server rejected the watch (e.g. key not found), or the transport error.
+type Watcher struct {
+	events chan *Response
+	mu     sync.Mutex
+	err    error
+}
+
+// Events returns the channel the watch events are sent to.
+func (w *Watcher) Events() <-chan *Response {
+	return w.events
+}
+
+// Err returns the reason the watch ended, or nil while it is running.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *Watcher) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+}
+
+// NewWatcher monitors a key for changes, like Watch, but also reports why
+// the watch ended.
+// The caller must use a context to control the Watcher's lifecycle. When the context is canceled, the watcher will stop and close the channel.
+func (c *Client) NewWatcher(ctx context.Context, key string, opts ...WatchOption) *Watcher {
+	w := &Watcher{
+		events: make(chan *Response, 1),
+	}
+
+	watchOpts := newWatchOptions(opts)
+
+	go c.watcher(ctx, key, watchOpts, w)
+
+	return w
+}
+
 // Watch monitors a key for changes.
 // It returns a read-only Response channel. When a change occurs, the response is sent through the channel.
 // The caller must use a context to control the Watcher's lifecycle. When the context is canceled, the watcher will stop and close the channel.
+// Use NewWatcher to learn why the channel was closed.
 func (c *Client) Watch(ctx context.Context, key string, opts ...WatchOption) <-chan *Response {
-	respChan := make(chan *Response, 1)
-
-	watchOpts := newWatchOptions(opts)
+	return c.NewWatcher(ctx, key, opts...).Events()
+}
 
-	go c.watcher(ctx, key, watchOpts, respChan)
+// watcher runs the watch loop in the background and records its outcome.
+func (c *Client) watcher(ctx context.Context, key string, opts *WatchOptions, w *Watcher) {
+	err := c.watchLoop(ctx, key, opts, w.events)
+	if ctx.Err() == nil {
+		c.logger.Warn("Watch stopped", "key", key, "err", err)
+	}
 
-	return respChan
+	// Record the error before closing the channel, which is how the caller is notified that the watch has ended.
+	w.setErr(err)
+	close(w.events)
 }
 
-// watcher is the long-polling loop that runs in the background.
-func (c *Client) watcher(ctx context.Context, key string, opts *WatchOptions, respChan chan<- *Response) {
-	// Ensure the channel is closed on goroutine exit, which is how the caller is notified that the watch has ended.
-	defer close(respChan)
+// watchLoop is the long-polling loop. It only returns on a terminal error.
+func (c *Client) watchLoop(ctx context.Context, key string, opts *WatchOptions, events chan<- *Response) error {
+	backoff := minReconnectBackoff
 
 	for {
-		query := url.Values{}
-		query.Set("wait", "true")
+		endpoint := c.cluster.pick()
 
-		if opts.recursive {
-			query.Set("recursive", "true")
+		var resp *Response
+		err := c.pinWaitIndex(ctx, key, opts)
+		if err == nil {
+			resp, err = c.poll(ctx, endpoint, key, opts)
 		}
-		// If waitIndex is greater than 0, add it to the query.
-		// This is the core of the loop: after each event, we use the new index + 1 to make the next request.
-		if opts.waitIndex > 0 {
-			query.Set("waitIndex", fmt.Sprintf("%d", opts.waitIndex))
+		if errors.Is(err, errPollTimeout) {
+			continue
 		}
-
-		URL := c.buildURL(key) + "?" + query.Encode()
-
-		// Create the request and pass the parent context into it.
-		// If the parent context is canceled, the request here will fail immediately,
-		// allowing for a graceful exit from the loop.
-		req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
-		if err != nil {
-			// This should not normally happen, but if it does, we cannot continue.
-			// We could send an error to an error channel here, but for simplicity, we just return.
-			fmt.Printf("watcher: failed to create request: %v\n", err)
-			return
+		if err == nil {
+			// Update waitIndex so the next request can get the next event.
+			// This is the key to achieving continuous watching!
+			opts.waitIndex = resp.Node.ModifiedIndex + 1
+		} else if IsEventIndexCleared(err) && ctx.Err() == nil {
+			// We fell too far behind and waitIndex is no longer in the server's
+			// event history. Start over from a fresh snapshot of the key.
+			resp, err = c.resync(ctx, key, opts, err)
 		}
-
-		// Execute the long-polling request.
-		resp, err := c.httpClient.Do(req)
 		if err != nil {
 			// When the context is canceled, an error will be received here. This is the expected way to exit.
-			// Check the context's error; if it's canceled, exit silently.
-			select {
-			case <-ctx.Done():
-				// Context was canceled, this is the intended way to stop the watcher.
-			default:
-				fmt.Printf("watcher: failed to execute HTTP request: %v\n", err)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !opts.reconnect || !isWatchRetryable(err) {
+				return err
 			}
-			return
-		}
-
-		respBody, err := io.ReadAll(resp.Body)
-		_ = resp.Body.Close()
-		if err != nil {
-			fmt.Printf("watcher: failed to read response body: %v\n", err)
-			return
-		}
-
-		var deimosResp Response
-		if err := json.Unmarshal(respBody, &deimosResp); err != nil {
-			fmt.Printf("watcher: failed to unmarshal JSON response: %v\n", err)
-			continue // It might be a temporary issue, so try the next iteration.
-		}
 
-		// If deimos returns an API error (e.g., key not found), stop the watcher.
-		if deimosResp.ErrorCode != 0 {
-			fmt.Printf("watcher: deimos API error, stopping watch: [%d] %s\n", deimosResp.ErrorCode, deimosResp.Message)
-			return
+			// Move away from the endpoint that failed us and resume from
+			// the same waitIndex, so no event is lost or duplicated.
+			c.logger.Warn("Watch reconnecting", "key", key, "waitIndex", opts.waitIndex, "backoff", backoff, "err", err)
+			c.cluster.failure(endpoint)
+			if err := sleepContext(ctx, backoff); err != nil {
+				return err
+			}
+			backoff = min(backoff*2, maxReconnectBackoff)
+			continue
 		}
-
-		// Update waitIndex so the next request can get the next event.
-		// This is the key to achieving continuous watching!
-		opts.waitIndex = deimosResp.Node.ModifiedIndex + 1
+		backoff = minReconnectBackoff
 
 		// Send the response to the channel.
 		// At the same time, check if the context has been canceled in case the caller
 		// has already exited while we are trying to send.
 		select {
-		case respChan <- &deimosResp:
+		case events <- resp:
 			// Sent successfully.
 		case <-ctx.Done():
 			// The context was canceled while we were trying to send.
-			return
+			return ctx.Err()
 		}
 	}
 }
+
+// pinWaitIndex starts a watch without a waitIndex from the current index
+// of the key when polls are bounded by a watch timeout. A poll that timed
+// out tells nothing about where the next one should start, so without a
+// waitIndex the events between two polls would be lost.
+func (c *Client) pinWaitIndex(ctx context.Context, key string, opts *WatchOptions) error {
+	if opts.waitIndex > 0 || c.watchTimeout <= 0 {
+		return nil
+	}
+
+	_, index, err := c.snapshot(ctx, key, opts)
+	if err != nil {
+		return err
+	}
+	opts.waitIndex = index + 1
+	return nil
+}
+
+// snapshot reads the current state of the watched key, along with the
+// index it was read at. The node is nil if the key does not exist.
+func (c *Client) snapshot(ctx context.Context, key string, opts *WatchOptions) (*Node, uint64, error) {
+	var getOpts []GetOption
+	if opts.recursive {
+		getOpts = append(getOpts, WithRecursive())
+	}
+
+	resp, err := c.Get(ctx, key, getOpts...)
+	switch {
+	case err == nil:
+		return resp.Node, observedIndex(resp), nil
+	case IsKeyNotFound(err):
+		return nil, errorIndex(err), nil
+	default:
+		return nil, 0, err
+	}
+}
+
+// resync reads the current state of the watched key and moves waitIndex
+// past it. The snapshot is returned as an ActionResync event, with a nil
+// Node if the key does not exist.
+func (c *Client) resync(ctx context.Context, key string, opts *WatchOptions, cleared error) (*Response, error) {
+	node, index, err := c.snapshot(ctx, key, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers that do not report their index in the headers still report it
+	// in the cleared error, which is at least as recent as anything the
+	// snapshot may have missed.
+	index = max(index, errorIndex(cleared))
+	opts.waitIndex = index + 1
+
+	return &Response{Action: ActionResync, Node: node, ClusterIndex: index}, nil
+}
+
+// latestModifiedIndex returns the highest ModifiedIndex in the tree.
+func latestModifiedIndex(node *Node) uint64 {
+	if node == nil {
+		return 0
+	}
+
+	index := node.ModifiedIndex
+	for _, child := range node.Nodes {
+		index = max(index, latestModifiedIndex(child))
+	}
+	return index
+}
+
+// poll issues a single long-poll for the next event on the key.
+func (c *Client) poll(ctx context.Context, endpoint, key string, opts *WatchOptions) (*Response, error) {
+	query := url.Values{}
+	query.Set("wait", "true")
+
+	if opts.recursive {
+		query.Set("recursive", "true")
+	}
+	// If waitIndex is greater than 0, add it to the query.
+	// This is the core of the loop: after each event, we use the new index + 1 to make the next request.
+	if opts.waitIndex > 0 {
+		query.Set("waitIndex", fmt.Sprintf("%d", opts.waitIndex))
+	}
+
+	URL := keysURL(endpoint, key) + "?" + query.Encode()
+
+	// Bound a single long-poll by the watch timeout, if any.
+	pollCtx, cancel := c.withWatchTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(pollCtx, "GET", URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create deimos request failed: %w", err)
+	}
+
+	resp, err := c.exchange(req)
+	if err != nil && ctx.Err() == nil && errors.Is(pollCtx.Err(), context.DeadlineExceeded) {
+		return nil, errPollTimeout
+	}
+	return resp, err
+}
+
+// isWatchRetryable reports whether a failed long-poll is worth retrying.
+// Errors about the request itself, like a missing key, are final, while
+// raft errors and transport errors are expected to go away.
+func isWatchRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode == ErrCodeRaftInternal || apiErr.ErrorCode == ErrCodeLeaderElect
+	}
+	return true
+}
+
+// sleepContext waits for the given duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// withWatchTimeout bounds a single long-poll by the client's watch timeout.
+func (c *Client) withWatchTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.watchTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.watchTimeout)
+}
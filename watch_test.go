@@ -0,0 +1,239 @@
+package deimosclient_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	deimosclient "github.com/marsevilspirit/deimos-client"
+	"github.com/marsevilspirit/deimos-client/deimostest"
+)
+
+// newTestClient starts a fake server that is closed with the test, and
+// returns a client connected to it.
+func newTestClient(t *testing.T, opts ...deimostest.Option) (*deimostest.Server, *deimosclient.Client) {
+	t.Helper()
+
+	srv := deimostest.NewServer(opts...)
+	t.Cleanup(srv.Close)
+	return srv, srv.Client()
+}
+
+// testContext returns a context that bounds a test.
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func nextEvent(t *testing.T, w *deimosclient.Watcher) *deimosclient.Response {
+	t.Helper()
+
+	select {
+	case resp, ok := <-w.Events():
+		if !ok {
+			t.Fatalf("watch ended: %v", w.Err())
+		}
+		return resp
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch event")
+		return nil
+	}
+}
+
+func TestWatchResync(t *testing.T) {
+	_, client := newTestClient(t, deimostest.WithHistorySize(1))
+	ctx := testContext(t)
+
+	for _, value := range []string{"v1", "v2", "v3"} {
+		if _, err := client.Set(ctx, "/foo", value); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+	}
+
+	w := client.NewWatcher(ctx, "/foo", deimosclient.WithWaitIndex(1))
+
+	resp := nextEvent(t, w)
+	if resp.Action != deimosclient.ActionResync {
+		t.Fatalf("action = %q, want %q", resp.Action, deimosclient.ActionResync)
+	}
+	if resp.Node == nil || resp.Node.Value != "v3" {
+		t.Fatalf("resync node = %+v, want the value v3", resp.Node)
+	}
+
+	if _, err := client.Set(ctx, "/foo", "v4"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if resp := nextEvent(t, w); resp.Action != "set" || resp.Node.Value != "v4" {
+		t.Errorf("event = %s %+v, want set v4", resp.Action, resp.Node)
+	}
+}
+
+func TestWatchResyncMissingKey(t *testing.T) {
+	_, client := newTestClient(t, deimostest.WithHistorySize(1))
+	ctx := testContext(t)
+
+	if _, err := client.Set(ctx, "/foo", "v1"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, err := client.Delete(ctx, "/foo"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	w := client.NewWatcher(ctx, "/foo", deimosclient.WithWaitIndex(1))
+
+	resp := nextEvent(t, w)
+	if resp.Action != deimosclient.ActionResync || resp.Node != nil {
+		t.Fatalf("event = %s %+v, want a resync without node", resp.Action, resp.Node)
+	}
+}
+
+// pollGate is a transport that holds back the second long-poll of a watch
+// until released, leaving a window in which no poll is outstanding.
+type pollGate struct {
+	polls   atomic.Int32
+	held    chan struct{}
+	release chan struct{}
+}
+
+func (g *pollGate) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("wait") == "true" && g.polls.Add(1) == 2 {
+		close(g.held)
+		<-g.release
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWatchTimeoutMissesNoEvent(t *testing.T) {
+	srv, client := newTestClient(t)
+	ctx := testContext(t)
+
+	gate := &pollGate{held: make(chan struct{}), release: make(chan struct{})}
+	watchClient := srv.Client(
+		deimosclient.WithTransport(gate),
+		deimosclient.WithWatchTimeout(100*time.Millisecond),
+	)
+	w := watchClient.NewWatcher(ctx, "/foo")
+
+	// Change the key after the first poll timed out, before the next one.
+	select {
+	case <-gate.held:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch poll not re-issued")
+	}
+	if _, err := client.Set(ctx, "/foo", "bar"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	close(gate.release)
+
+	if resp := nextEvent(t, w); resp.Action != "set" || resp.Node.Value != "bar" {
+		t.Errorf("event = %s %+v, want set bar", resp.Action, resp.Node)
+	}
+}
+
+// connDropper is a transport that can drop its open connections, as a
+// network failure would. Connections are not reused, so that the transport
+// does not retry dropped requests by itself.
+type connDropper struct {
+	transport *http.Transport
+	polls     atomic.Int32
+
+	mu    sync.Mutex
+	conns []net.Conn
+	dials int
+}
+
+func newConnDropper() *connDropper {
+	d := &connDropper{}
+	dialer := &net.Dialer{}
+	d.transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			d.mu.Lock()
+			d.conns = append(d.conns, conn)
+			d.dials++
+			d.mu.Unlock()
+			return conn, nil
+		},
+		DisableKeepAlives: true,
+	}
+	return d
+}
+
+func (d *connDropper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("wait") == "true" {
+		d.polls.Add(1)
+	}
+	return d.transport.RoundTrip(req)
+}
+
+func (d *connDropper) dialed() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dials
+}
+
+func (d *connDropper) drop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, conn := range d.conns {
+		_ = conn.Close()
+	}
+	d.conns = nil
+}
+
+func TestWatchReconnectAfterDrop(t *testing.T) {
+	srv, client := newTestClient(t)
+	ctx := testContext(t)
+
+	set, err := client.Set(ctx, "/foo", "v1")
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	dropper := newConnDropper()
+	watchClient := srv.Client(deimosclient.WithTransport(dropper))
+	w := watchClient.NewWatcher(ctx, "/foo",
+		deimosclient.WithWaitIndex(set.Node.ModifiedIndex),
+		deimosclient.WithReconnect(),
+	)
+	if resp := nextEvent(t, w); resp.Node.Value != "v1" {
+		t.Fatalf("event = %s %+v, want v1", resp.Action, resp.Node)
+	}
+
+	// Drop the connection of the next poll while it waits.
+	waitFor(t, "next poll", func() bool { return dropper.dialed() >= 2 })
+	dropper.drop()
+
+	for _, value := range []string{"v2", "v3"} {
+		if _, err := client.Set(ctx, "/foo", value); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+	}
+	for _, value := range []string{"v2", "v3"} {
+		if resp := nextEvent(t, w); resp.Action != "set" || resp.Node.Value != value {
+			t.Fatalf("event = %s %+v, want set %s", resp.Action, resp.Node, value)
+		}
+	}
+
+	// Nothing was delivered twice: the next event is the next change.
+	if _, err := client.Set(ctx, "/foo", "v4"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if resp := nextEvent(t, w); resp.Node.Value != "v4" {
+		t.Errorf("event = %s %+v, want v4", resp.Action, resp.Node)
+	}
+	if dropper.polls.Load() < 5 {
+		t.Errorf("%d polls, want the watch to have reconnected", dropper.polls.Load())
+	}
+}